@@ -0,0 +1,175 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/istrangers/demolanguage/file"
+	"github.com/istrangers/demolanguage/token"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// JSONSchemaVersion is the version of the JSON form of the AST. It changes
+// whenever a node type or field is added, removed, renamed or gets another
+// representation.
+const JSONSchemaVersion = 1
+
+// ToJSON returns the JSON form of program, an object holding schemaVersion,
+// fileName and the program node.
+//
+// A node is an object whose first fields are type, the name of its Go type,
+// start and end, followed by its own fields in declaration order with the
+// first letter lowered. Positions are objects holding the byte offset in the
+// file, the line and the column. Tokens are written as their string form,
+// numbers too large for a JSON number as strings. Fields derived by the
+// parser, like the declarations of a scope or the source of a function, are
+// left out.
+func ToJSON(program *Program) ([]byte, error) {
+	fileName := ""
+	if program.File != nil {
+		fileName = program.File.Name
+	}
+	encoder := &jsonEncoder{file: program.File}
+	return marshalJSON(jsonObject{
+		{"schemaVersion", JSONSchemaVersion},
+		{"fileName", fileName},
+		{"program", encoder.node(program)},
+	})
+}
+
+// jsonSkippedFields are the fields derived from other fields or not part of
+// the tree, by node type and field name.
+var jsonSkippedFields = map[string]bool{
+	"Program.DeclarationList":              true,
+	"Program.File":                         true,
+	"Program.TriviaMap":                    true,
+	"Program.Dangling":                     true,
+	"FunLiteral.DeclarationList":           true,
+	"FunLiteral.FunDefinition":             true,
+	"ArrowFunctionLiteral.DeclarationList": true,
+	"ArrowFunctionLiteral.FunDefinition":   true,
+	"ClassDeclaration.ClassDefinition":     true,
+	"StaticBlockDeclaration.Source":        true,
+}
+
+type jsonField struct {
+	key   string
+	value any
+}
+
+// jsonObject is a JSON object that keeps the order of its fields.
+type jsonObject []jsonField
+
+func (self jsonObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, field := range self {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, _ := marshalJSON(field.key)
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		value, err := marshalJSON(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal without escaping HTML characters, which are
+// common in operators.
+func marshalJSON(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+type jsonEncoder struct {
+	file *file.File
+}
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	indexType = reflect.TypeOf(file.Index(0))
+	tokenType = reflect.TypeOf(token.Token(0))
+)
+
+func (self *jsonEncoder) position(index file.Index) jsonObject {
+	if self.file == nil {
+		return jsonObject{{"offset", int(index)}}
+	}
+	position := self.file.PositionByIndex(index)
+	return jsonObject{
+		{"offset", int(index) - self.file.BaseOffset},
+		{"line", position.Line},
+		{"column", position.Column},
+	}
+}
+
+func (self *jsonEncoder) node(node Node) jsonObject {
+	value := reflect.ValueOf(node).Elem()
+	object := jsonObject{
+		{"type", value.Type().Name()},
+		{"start", self.position(node.StartIndex())},
+		{"end", self.position(node.EndIndex())},
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous || jsonSkippedFields[value.Type().Name()+"."+field.Name] {
+			continue
+		}
+		key := strings.ToLower(field.Name[:1]) + field.Name[1:]
+		object = append(object, jsonField{key, self.value(value.Field(i))})
+	}
+	return object
+}
+
+func (self *jsonEncoder) value(value reflect.Value) any {
+	switch value.Type() {
+	case indexType:
+		return self.position(file.Index(value.Int()))
+	case tokenType:
+		return token.Token(value.Int()).String()
+	}
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		if value.Type().Implements(nodeType) {
+			return self.node(value.Interface().(Node))
+		}
+		return self.literal(value.Interface())
+	case reflect.Slice:
+		list := make([]any, value.Len())
+		for i := range list {
+			list[i] = self.value(value.Index(i))
+		}
+		return list
+	}
+	return value.Interface()
+}
+
+// literal returns the value of a number literal.
+func (self *jsonEncoder) literal(value any) any {
+	switch value := value.(type) {
+	case float64:
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return fmt.Sprint(value)
+		}
+	case *big.Int:
+		return value.String()
+	}
+	return value
+}
@@ -0,0 +1,158 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/parser"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	content := "// one\nvar a = 123456789012345678901234567890n\nif a < 1 {}\n"
+	program, err := parser.CreateParser(1, "test.dl", content, false, true).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		SchemaVersion int
+		FileName      string
+		Program       struct {
+			Type   string
+			Body   []map[string]any
+			Trivia []map[string]any
+		}
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.SchemaVersion != ast.JSONSchemaVersion || document.FileName != "test.dl" ||
+		document.Program.Type != "Program" || len(document.Program.Body) != 2 || len(document.Program.Trivia) != 1 {
+		t.Fatalf("document %s", data)
+	}
+	varStatement, ifStatement := document.Program.Body[0], document.Program.Body[1]
+	start := varStatement["start"].(map[string]any)
+	if varStatement["type"] != "VarStatement" || varStatement["token"] != "var" ||
+		start["offset"] != 7.0 || start["line"] != 2.0 || start["column"] != 1.0 {
+		t.Errorf("var statement %v", varStatement)
+	}
+	value := varStatement["list"].([]any)[0].(map[string]any)["initializer"].(map[string]any)["value"]
+	if value != "123456789012345678901234567890" {
+		t.Errorf("big int value %v", value)
+	}
+	condition := ifStatement["condition"].(map[string]any)
+	if condition["operator"] != "<" || ifStatement["alternate"] != nil {
+		t.Errorf("if statement %v", ifStatement)
+	}
+	if !strings.Contains(string(data), `"operator":"<"`) {
+		t.Errorf("escaped operator in %s", data)
+	}
+}
+
+// TestJSONKeys pins the keys written for each node type, a change here needs a
+// new JSONSchemaVersion.
+func TestJSONKeys(t *testing.T) {
+	content := "// c\nclass A extends B implements C {\n  public static n = 1\n  static { n = 2 }\n  protected m(a = 1) { return a }\n}\nfun f(x) { return x }\nvar g = (y) -> y + 1\nfor var k, v in {a: [1]} { continue }\nfor const w of [1] { break }\nl: while true { break l }\ndo {} while false\nfor var i = 0; i < 1; i++ {}\nswitch 1 { case 1 {} default {} }\ntry { throw new A() } catch (e) {} finally {}\nif a.b[0] {} else {}\nvar t = `x${1}` + tag`y` + !c ? null : this\no.p = -1n ** 2.5 && \"s\" == false\n"
+	program, err := parser.CreateParser(1, "test.dl", content, false, true).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"ArrayLiteral":             "leftBracket values rightBracket",
+		"ArrowFunctionLiteral":     "index parameterList arrow body",
+		"AssignExpression":         "left operator right",
+		"BinaryExpression":         "operator left right comparison",
+		"Binding":                  "target initializer",
+		"BlockStatement":           "leftBrace body rightBrace",
+		"BooleanLiteral":           "index value",
+		"BracketExpression":        "left leftBracket expression rightBracket",
+		"BreakStatement":           "break label",
+		"CaseStatement":            "case condition consequent",
+		"ClassDeclaration":         "index name superClass interfaces leftBrace body rightBrace",
+		"ConditionalExpression":    "test consequent alternate",
+		"ContinueStatement":        "continue label",
+		"DoWhileStatement":         "do body condition",
+		"DotExpression":            "left dot identifier",
+		"ExpressionStatement":      "expression",
+		"FieldDeclaration":         "index accessModifier static name initializer",
+		"ForInStatement":           "for token key value source body",
+		"ForOfStatement":           "for token value source body",
+		"ForStatement":             "for initializer condition update body",
+		"FunLiteral":               "fun name parameterList body",
+		"FunStatement":             "funLiteral",
+		"Identifier":               "index name",
+		"IfStatement":              "if condition consequent alternate",
+		"LabelledStatement":        "label colon statement",
+		"MethodDeclaration":        "index accessModifier static body",
+		"NewExpression":            "new callee leftParenthesis arguments rightParenthesis",
+		"NullLiteral":              "index",
+		"NumberLiteral":            "index literal value",
+		"ObjectLiteral":            "leftBrace properties rightBrace",
+		"ParameterList":            "leftParenthesis list rightParenthesis",
+		"Program":                  "body trivia",
+		"PropertyKeyValue":         "name colon value",
+		"ReturnStatement":          "return arguments",
+		"StaticBlockDeclaration":   "index body",
+		"StringLiteral":            "index literal value",
+		"SwitchStatement":          "switch discriminant body default rightBrace",
+		"TemplateElement":          "index literal parsed",
+		"TemplateLiteral":          "tag openQuote elements expressions closeQuote",
+		"ThisExpression":           "index",
+		"ThrowStatement":           "throw argument",
+		"Trivia":                   "token index literal",
+		"TryCatchFinallyStatement": "try tryBody catchParameters catchBody finallyBody",
+		"UnaryExpression":          "index operator operand postfix",
+		"VarStatement":             "var token list",
+		"WhileStatement":           "while condition body",
+	}
+	keys := map[string]string{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var value func()
+	value = func() {
+		delim, _ := decoder.Token()
+		switch delim {
+		case json.Delim('{'):
+			var names []string
+			nodeType := ""
+			for decoder.More() {
+				key, _ := decoder.Token()
+				names = append(names, key.(string))
+				if key == "type" {
+					name, _ := decoder.Token()
+					nodeType = name.(string)
+				} else {
+					value()
+				}
+			}
+			decoder.Token()
+			if _, exists := keys[nodeType]; nodeType != "" && !exists {
+				keys[nodeType] = strings.Join(names[3:], " ")
+			}
+		case json.Delim('['):
+			for decoder.More() {
+				value()
+			}
+			decoder.Token()
+		}
+	}
+	value()
+	for nodeType, want := range expected {
+		if keys[nodeType] != want {
+			t.Errorf("%s: got keys %q, want %q", nodeType, keys[nodeType], want)
+		}
+	}
+	for nodeType := range keys {
+		if _, exists := expected[nodeType]; !exists {
+			t.Errorf("%s: keys not pinned", nodeType)
+		}
+	}
+}
@@ -9,6 +9,64 @@ type Program struct {
 	Body            []Statement
 	DeclarationList []*VariableDeclaration
 	File            *file.File
+
+	// Trivia holds every comment and white space run in source order, TriviaMap
+	// the trivia attached to statements, cases and class members, and Dangling
+	// the trivia after the last statement. They are only filled in concrete
+	// syntax mode.
+	Trivia    []*Trivia
+	TriviaMap TriviaMap
+	Dangling  []*Trivia
+}
+
+// Trivia is a comment or a run of white space. A white space run ends after
+// a line terminator.
+type Trivia struct {
+	Token   token.Token // COMMENT, MULTI_COMMENT or WHITE_SPACE
+	Index   file.Index
+	Literal string
+}
+
+func (self *Trivia) StartIndex() file.Index {
+	return self.Index
+}
+func (self *Trivia) EndIndex() file.Index {
+	return file.Index(int(self.Index) + len(self.Literal))
+}
+
+// NodeTrivia is the trivia attached to a node. Leading precedes the node,
+// Trailing follows it on the same line and Dangling precedes the closing
+// brace of a node with an empty or finished body.
+type NodeTrivia struct {
+	Leading  []*Trivia
+	Trailing []*Trivia
+	Dangling []*Trivia
+}
+
+type TriviaMap map[Node]*NodeTrivia
+
+// Leading returns the trivia in front of node.
+func (self TriviaMap) Leading(node Node) []*Trivia {
+	if nodeTrivia := self[node]; nodeTrivia != nil {
+		return nodeTrivia.Leading
+	}
+	return nil
+}
+
+// Trailing returns the trivia after node on the same line.
+func (self TriviaMap) Trailing(node Node) []*Trivia {
+	if nodeTrivia := self[node]; nodeTrivia != nil {
+		return nodeTrivia.Trailing
+	}
+	return nil
+}
+
+// Dangling returns the trivia in front of the closing brace of node.
+func (self TriviaMap) Dangling(node Node) []*Trivia {
+	if nodeTrivia := self[node]; nodeTrivia != nil {
+		return nodeTrivia.Dangling
+	}
+	return nil
 }
 
 type Node interface {
@@ -46,8 +104,9 @@ type (
 
 	VarStatement struct {
 		AbstractStatement
-		Var  file.Index
-		List []*Binding
+		Var   file.Index
+		Token token.Token // VAR, LET or CONST
+		List  []*Binding
 	}
 
 	FunStatement struct {
@@ -78,6 +137,39 @@ type (
 		Body        Statement
 	}
 
+	ForInStatement struct {
+		AbstractStatement
+		For    file.Index
+		Token  token.Token
+		Key    *Identifier
+		Value  *Identifier
+		Source Expression
+		Body   Statement
+	}
+
+	ForOfStatement struct {
+		AbstractStatement
+		For    file.Index
+		Token  token.Token
+		Value  *Identifier
+		Source Expression
+		Body   Statement
+	}
+
+	WhileStatement struct {
+		AbstractStatement
+		While     file.Index
+		Condition Expression
+		Body      Statement
+	}
+
+	DoWhileStatement struct {
+		AbstractStatement
+		Do        file.Index
+		Body      Statement
+		Condition Expression
+	}
+
 	SwitchStatement struct {
 		AbstractStatement
 		Switch       file.Index
@@ -102,11 +194,20 @@ type (
 	BreakStatement struct {
 		AbstractStatement
 		Break file.Index
+		Label *Identifier
 	}
 
 	ContinueStatement struct {
 		AbstractStatement
 		Continue file.Index
+		Label    *Identifier
+	}
+
+	LabelledStatement struct {
+		AbstractStatement
+		Label     *Identifier
+		Colon     file.Index
+		Statement Statement
 	}
 
 	ThrowStatement struct {
@@ -128,6 +229,25 @@ type (
 func (self *AbstractStatement) statement() {
 }
 
+func (self *Program) StartIndex() file.Index {
+	if self.File != nil {
+		return file.Index(self.File.BaseOffset)
+	}
+	if len(self.Body) > 0 {
+		return self.Body[0].StartIndex()
+	}
+	return 0
+}
+func (self *Program) EndIndex() file.Index {
+	if self.File != nil {
+		return file.Index(self.File.BaseOffset + len(self.File.Content))
+	}
+	if len(self.Body) > 0 {
+		return self.Body[len(self.Body)-1].EndIndex()
+	}
+	return 0
+}
+
 func (self *BadStatement) StartIndex() file.Index {
 	return self.Start
 }
@@ -192,6 +312,34 @@ func (self *ForStatement) EndIndex() file.Index {
 	return self.Body.EndIndex()
 }
 
+func (self *ForInStatement) StartIndex() file.Index {
+	return self.For
+}
+func (self *ForInStatement) EndIndex() file.Index {
+	return self.Body.EndIndex()
+}
+
+func (self *ForOfStatement) StartIndex() file.Index {
+	return self.For
+}
+func (self *ForOfStatement) EndIndex() file.Index {
+	return self.Body.EndIndex()
+}
+
+func (self *WhileStatement) StartIndex() file.Index {
+	return self.While
+}
+func (self *WhileStatement) EndIndex() file.Index {
+	return self.Body.EndIndex()
+}
+
+func (self *DoWhileStatement) StartIndex() file.Index {
+	return self.Do
+}
+func (self *DoWhileStatement) EndIndex() file.Index {
+	return self.Condition.EndIndex()
+}
+
 func (self *SwitchStatement) StartIndex() file.Index {
 	return self.Switch
 }
@@ -210,6 +358,9 @@ func (self *BreakStatement) StartIndex() file.Index {
 	return self.Break
 }
 func (self *BreakStatement) EndIndex() file.Index {
+	if self.Label != nil {
+		return self.Label.EndIndex()
+	}
 	return self.Break + 5
 }
 func (self *BreakStatement) Token() token.Token {
@@ -220,12 +371,22 @@ func (self *ContinueStatement) StartIndex() file.Index {
 	return self.Continue
 }
 func (self *ContinueStatement) EndIndex() file.Index {
+	if self.Label != nil {
+		return self.Label.EndIndex()
+	}
 	return self.Continue + 8
 }
 func (self *ContinueStatement) Token() token.Token {
 	return token.CONTINUE
 }
 
+func (self *LabelledStatement) StartIndex() file.Index {
+	return self.Label.StartIndex()
+}
+func (self *LabelledStatement) EndIndex() file.Index {
+	return self.Statement.EndIndex()
+}
+
 func (self *ThrowStatement) StartIndex() file.Index {
 	return self.Throw
 }
@@ -297,6 +458,22 @@ type (
 		Value   string
 	}
 
+	TemplateElement struct {
+		AbstractExpression
+		Index   file.Index
+		Literal string
+		Parsed  string
+	}
+
+	TemplateLiteral struct {
+		AbstractExpression
+		Tag         Expression
+		OpenQuote   file.Index
+		Elements    []*TemplateElement
+		Expressions []Expression
+		CloseQuote  file.Index
+	}
+
 	BooleanLiteral struct {
 		AbstractExpression
 		Index file.Index
@@ -356,6 +533,13 @@ type (
 		FunDefinition   string
 	}
 
+	ConditionalExpression struct {
+		AbstractExpression
+		Test       Expression
+		Consequent Expression
+		Alternate  Expression
+	}
+
 	BinaryExpression struct {
 		AbstractExpression
 		Operator   token.Token
@@ -457,6 +641,23 @@ func (self *StringLiteral) EndIndex() file.Index {
 	return file.Index(int(self.Index) + len(self.Literal))
 }
 
+func (self *TemplateElement) StartIndex() file.Index {
+	return self.Index
+}
+func (self *TemplateElement) EndIndex() file.Index {
+	return file.Index(int(self.Index) + len(self.Literal))
+}
+
+func (self *TemplateLiteral) StartIndex() file.Index {
+	if self.Tag != nil {
+		return self.Tag.StartIndex()
+	}
+	return self.OpenQuote
+}
+func (self *TemplateLiteral) EndIndex() file.Index {
+	return self.CloseQuote + 1
+}
+
 func (self *BooleanLiteral) StartIndex() file.Index {
 	return self.Index
 }
@@ -516,6 +717,13 @@ func (self *ArrowFunctionLiteral) EndIndex() file.Index {
 	return self.Body.EndIndex()
 }
 
+func (self *ConditionalExpression) StartIndex() file.Index {
+	return self.Test.StartIndex()
+}
+func (self *ConditionalExpression) EndIndex() file.Index {
+	return self.Alternate.EndIndex()
+}
+
 func (self *BinaryExpression) StartIndex() file.Index {
 	return self.Left.StartIndex()
 }
@@ -568,7 +776,7 @@ func (self *NewExpression) StartIndex() file.Index {
 	return self.New
 }
 func (self *NewExpression) EndIndex() file.Index {
-	return self.Callee.EndIndex()
+	return self.RightParenthesis + 1
 }
 
 func (self *BadExpression) StartIndex() file.Index {
@@ -580,11 +788,13 @@ func (self *BadExpression) EndIndex() file.Index {
 
 type (
 	VariableDeclaration struct {
-		Var  file.Index
-		List []*Binding
+		Var   file.Index
+		Token token.Token
+		List  []*Binding
 	}
 
 	Declaration interface {
+		Node
 		declaration()
 	}
 
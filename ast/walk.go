@@ -0,0 +1,216 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If the
+// visitor it returns is not nil, Walk visits each of the children of the node
+// with it, followed by a call of Visit(nil).
+type Visitor interface {
+	Visit(node Node) (visitor Visitor)
+}
+
+// Walk traverses an AST in depth-first order, visiting the children of a node
+// in source order.
+func Walk(visitor Visitor, node Node) {
+	if visitor = visitor.Visit(node); visitor == nil {
+		return
+	}
+	replaceChildren(node, func(child Node) Node {
+		Walk(visitor, child)
+		return child
+	})
+	visitor.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (self inspector) Visit(node Node) Visitor {
+	if self(node) {
+		return self
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order. It calls f for each node and
+// continues with its children if f returns true, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses an AST in depth-first order and replaces each node by the
+// result of f, which is called once the children of the node are rewritten.
+// A nil result drops the node from a list or clears the field holding it. It
+// panics if the result does not fit in place of the node, like a statement in
+// place of an expression.
+func Rewrite(node Node, f func(Node) Node) Node {
+	replaceChildren(node, func(child Node) Node {
+		return Rewrite(child, f)
+	})
+	return f(node)
+}
+
+// replaceNode replaces a node that is not nil and checks that the result fits.
+func replaceNode[T Node](replace func(Node) Node, node T) T {
+	var null T
+	if any(node) == any(null) {
+		return node
+	}
+	result := replace(node)
+	if result == nil {
+		return null
+	}
+	replacement, ok := result.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast: cannot replace %T with %T", node, result))
+	}
+	return replacement
+}
+
+func replaceList[T Node](replace func(Node) Node, list []T) []T {
+	var null T
+	result := list[:0]
+	for _, node := range list {
+		if any(node) != any(null) {
+			if node = replaceNode(replace, node); any(node) == any(null) {
+				continue
+			}
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// replaceChildren calls replace for each child of node in source order and
+// stores the results back.
+func replaceChildren(node Node, replace func(Node) Node) {
+	switch node := node.(type) {
+	case *Program:
+		node.Body = replaceList(replace, node.Body)
+
+	case *ExpressionStatement:
+		node.Expression = replaceNode(replace, node.Expression)
+	case *BlockStatement:
+		node.Body = replaceList(replace, node.Body)
+	case *VarStatement:
+		node.List = replaceList(replace, node.List)
+	case *FunStatement:
+		node.FunLiteral = replaceNode(replace, node.FunLiteral)
+	case *ReturnStatement:
+		node.Arguments = replaceList(replace, node.Arguments)
+	case *IfStatement:
+		node.Condition = replaceNode(replace, node.Condition)
+		node.Consequent = replaceNode(replace, node.Consequent)
+		node.Alternate = replaceNode(replace, node.Alternate)
+	case *ForStatement:
+		node.Initializer = replaceNode(replace, node.Initializer)
+		node.Condition = replaceNode(replace, node.Condition)
+		node.Update = replaceNode(replace, node.Update)
+		node.Body = replaceNode(replace, node.Body)
+	case *ForInStatement:
+		node.Key = replaceNode(replace, node.Key)
+		node.Value = replaceNode(replace, node.Value)
+		node.Source = replaceNode(replace, node.Source)
+		node.Body = replaceNode(replace, node.Body)
+	case *ForOfStatement:
+		node.Value = replaceNode(replace, node.Value)
+		node.Source = replaceNode(replace, node.Source)
+		node.Body = replaceNode(replace, node.Body)
+	case *WhileStatement:
+		node.Condition = replaceNode(replace, node.Condition)
+		node.Body = replaceNode(replace, node.Body)
+	case *DoWhileStatement:
+		node.Body = replaceNode(replace, node.Body)
+		node.Condition = replaceNode(replace, node.Condition)
+	case *SwitchStatement:
+		node.Discriminant = replaceNode(replace, node.Discriminant)
+		node.Body = replaceList(replace, node.Body)
+	case *CaseStatement:
+		node.Condition = replaceNode(replace, node.Condition)
+		node.Consequent = replaceNode(replace, node.Consequent)
+	case *BreakStatement:
+		node.Label = replaceNode(replace, node.Label)
+	case *ContinueStatement:
+		node.Label = replaceNode(replace, node.Label)
+	case *LabelledStatement:
+		node.Label = replaceNode(replace, node.Label)
+		node.Statement = replaceNode(replace, node.Statement)
+	case *ThrowStatement:
+		node.Argument = replaceNode(replace, node.Argument)
+	case *TryCatchFinallyStatement:
+		node.TryBody = replaceNode(replace, node.TryBody)
+		node.CatchParameters = replaceNode(replace, node.CatchParameters)
+		node.CatchBody = replaceNode(replace, node.CatchBody)
+		node.FinallyBody = replaceNode(replace, node.FinallyBody)
+
+	case *Binding:
+		node.Target = replaceNode(replace, node.Target)
+		node.Initializer = replaceNode(replace, node.Initializer)
+	case *AssignExpression:
+		node.Left = replaceNode(replace, node.Left)
+		node.Right = replaceNode(replace, node.Right)
+	case *TemplateLiteral:
+		node.Tag = replaceNode(replace, node.Tag)
+		for i := range node.Elements {
+			node.Elements[i] = replaceNode(replace, node.Elements[i])
+			if i < len(node.Expressions) {
+				node.Expressions[i] = replaceNode(replace, node.Expressions[i])
+			}
+		}
+	case *ArrayLiteral:
+		node.Values = replaceList(replace, node.Values)
+	case *ObjectLiteral:
+		node.Properties = replaceList(replace, node.Properties)
+	case *PropertyKeyValue:
+		node.Name = replaceNode(replace, node.Name)
+		node.Value = replaceNode(replace, node.Value)
+	case *ParameterList:
+		node.List = replaceList(replace, node.List)
+	case *FunLiteral:
+		node.Name = replaceNode(replace, node.Name)
+		node.ParameterList = replaceNode(replace, node.ParameterList)
+		node.Body = replaceNode(replace, node.Body)
+	case *ArrowFunctionLiteral:
+		node.ParameterList = replaceNode(replace, node.ParameterList)
+		node.Body = replaceNode(replace, node.Body)
+	case *ConditionalExpression:
+		node.Test = replaceNode(replace, node.Test)
+		node.Consequent = replaceNode(replace, node.Consequent)
+		node.Alternate = replaceNode(replace, node.Alternate)
+	case *BinaryExpression:
+		node.Left = replaceNode(replace, node.Left)
+		node.Right = replaceNode(replace, node.Right)
+	case *UnaryExpression:
+		node.Operand = replaceNode(replace, node.Operand)
+	case *DotExpression:
+		node.Left = replaceNode(replace, node.Left)
+		node.Identifier = replaceNode(replace, node.Identifier)
+	case *BracketExpression:
+		node.Left = replaceNode(replace, node.Left)
+		node.Expression = replaceNode(replace, node.Expression)
+	case *CallExpression:
+		node.Callee = replaceNode(replace, node.Callee)
+		node.Arguments = replaceList(replace, node.Arguments)
+	case *NewExpression:
+		node.Callee = replaceNode(replace, node.Callee)
+		node.Arguments = replaceList(replace, node.Arguments)
+
+	case *InterfaceDeclaration:
+		node.Body = replaceList(replace, node.Body)
+	case *ClassDeclaration:
+		node.Name = replaceNode(replace, node.Name)
+		node.SuperClass = replaceNode(replace, node.SuperClass)
+		node.Interfaces = replaceList(replace, node.Interfaces)
+		node.Body = replaceList(replace, node.Body)
+	case *StaticBlockDeclaration:
+		node.Body = replaceNode(replace, node.Body)
+	case *FieldDeclaration:
+		node.Name = replaceNode(replace, node.Name)
+		node.Initializer = replaceNode(replace, node.Initializer)
+	case *MethodDeclaration:
+		node.Body = replaceNode(replace, node.Body)
+
+	case *BadStatement, *BadExpression, *BadDeclaration, *Identifier, *NumberLiteral,
+		*StringLiteral, *TemplateElement, *BooleanLiteral, *NullLiteral, *ThisExpression:
+		// Leaves.
+	}
+}
@@ -0,0 +1,49 @@
+package ast_test
+
+import (
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/parser"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	content := "class A extends B {\n  public f(x = 1) { return x }\n}\nswitch 1 {\ncase 1 { var y = `a${1}b` }\n}\n"
+	program, err := parser.CreateParser(1, "", content, true, true).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var identifiers []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		if identifier, ok := node.(*ast.Identifier); ok {
+			identifiers = append(identifiers, identifier.Name)
+		}
+		depth++
+		return true
+	})
+	if strings.Join(identifiers, ",") != "A,B,f,x,x,y" || depth != 0 {
+		t.Errorf("identifiers %v, depth %d", identifiers, depth)
+	}
+
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if numberLiteral, ok := node.(*ast.NumberLiteral); ok {
+			return &ast.StringLiteral{Index: numberLiteral.Index, Literal: `"one"`, Value: "one"}
+		}
+		return node
+	})
+	var values []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stringLiteral, ok := node.(*ast.StringLiteral); ok {
+			values = append(values, stringLiteral.Value)
+		}
+		return true
+	})
+	if strings.Join(values, ",") != "one,one,one,one" {
+		t.Errorf("rewritten literals %v", values)
+	}
+}
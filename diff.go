@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines compares two lists of lines by their longest common subsequence,
+// listing the deleted lines of a change before the inserted ones.
+func diffLines(before, after []string) []diffLine {
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			lines = append(lines, diffLine{' ', before[i]})
+			i, j = i+1, j+1
+		case j == len(after) || i < len(before) && common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', before[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', after[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// unifiedDiff returns the changes from before to after in unified format.
+func unifiedDiff(fileName string, before, after string) string {
+	lines := diffLines(splitLines(before), splitLines(after))
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s.orig\n+++ %s\n", fileName, fileName)
+	// beforeLine and afterLine count the lines in front of index.
+	beforeLine, afterLine := 0, 0
+	for index := 0; index < len(lines); {
+		if lines[index].kind == ' ' {
+			beforeLine, afterLine, index = beforeLine+1, afterLine+1, index+1
+			continue
+		}
+		// A hunk starts with up to diffContext lines of context and goes on
+		// while the next change is at most twice that far away.
+		start := max(index-diffContext, 0)
+		end, unchanged := index, 0
+		for end < len(lines) && unchanged <= 2*diffContext {
+			if lines[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		end -= max(unchanged-diffContext, 0)
+		hunkBefore, hunkAfter := beforeLine-(index-start), afterLine-(index-start)
+		beforeCount, afterCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.kind != '+' {
+				beforeCount++
+			}
+			if line.kind != '-' {
+				afterCount++
+			}
+		}
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n", hunkRange(hunkBefore, beforeCount), hunkRange(hunkAfter, afterCount))
+		for _, line := range lines[start:end] {
+			builder.WriteByte(line.kind)
+			builder.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				builder.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		beforeLine += beforeCount - (index - start)
+		afterLine += afterCount - (index - start)
+		index = end
+	}
+	return builder.String()
+}
+
+// hunkRange formats the start line and length of a hunk side, an empty side
+// names the line before it.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line+1)
+	}
+	return fmt.Sprintf("%d,%d", line+1, count)
+}
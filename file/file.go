@@ -4,10 +4,21 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"unicode/utf8"
 )
 
 type Index int
 
+// ColumnMode selects the unit in which Position.Column is counted.
+type ColumnMode int
+
+const (
+	// ColumnCharacters counts Unicode characters, one per code point.
+	ColumnCharacters ColumnMode = iota
+	// ColumnUTF16 counts UTF-16 code units, as editors speaking LSP expect.
+	ColumnUTF16
+)
+
 type Position struct {
 	FileName string
 	Line     int
@@ -39,6 +50,7 @@ type File struct {
 	Content           string
 	LineOffsets       []int
 	LastScannedOffset int
+	ColumnMode        ColumnMode
 }
 
 func (file *File) PositionByIndex(index Index) *Position {
@@ -49,7 +61,7 @@ func (file *File) Position(offset int) *Position {
 	var line int
 	var lineOffsets []int
 
-	lock := file.Lock
+	lock := &file.Lock
 	if offset > file.LastScannedOffset {
 		lock.Lock()
 		lineOffsets, line = file.scanToOffset(offset)
@@ -63,10 +75,11 @@ func (file *File) Position(offset int) *Position {
 		}) - 1
 	}
 
-	col := offset + 1
-	if len(lineOffsets) > 0 {
-		col -= lineOffsets[line]
+	lineOffset := 0
+	if line >= 0 {
+		lineOffset = lineOffsets[line]
 	}
+	col := file.column(lineOffset, offset) + 1
 	row := line + 2
 
 	return &Position{
@@ -76,6 +89,26 @@ func (file *File) Position(offset int) *Position {
 	}
 }
 
+// column counts the characters between the start of a line and the byte offset.
+func (file *File) column(lineOffset int, offset int) int {
+	if offset > len(file.Content) {
+		return offset - lineOffset
+	}
+	text := file.Content[lineOffset:offset]
+	if file.ColumnMode != ColumnUTF16 {
+		return utf8.RuneCountInString(text)
+	}
+	column := 0
+	for _, chr := range text {
+		column++
+		if chr > 0xffff {
+			// Characters outside the basic multilingual plane are surrogate pairs.
+			column++
+		}
+	}
+	return column
+}
+
 func (file *File) scanToOffset(offset int) ([]int, int) {
 	for file.LastScannedOffset < offset {
 		lineOffset := file.findLineOffset(file.Content[file.LastScannedOffset:])
@@ -3,7 +3,11 @@ package interpreter
 import (
 	"fmt"
 	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"math"
+	"math/big"
+	"strings"
 )
 
 func (self *Interpreter) evaluateExpression(expression ast.Expression) Value {
@@ -13,11 +17,16 @@ func (self *Interpreter) evaluateExpression(expression ast.Expression) Value {
 	case *ast.BooleanLiteral:
 		return self.evaluateBooleanLiteral(expr.Value)
 	case *ast.NumberLiteral:
+		if _, ok := expr.Value.(*big.Int); ok {
+			return self.panic("BigInt literals are not supported by the interpreter", expr.StartIndex())
+		}
 		return self.evaluateNumberLiteral(expr.Value)
 	case *ast.StringLiteral:
 		return self.evaluateStringLiteral(expr.Value)
+	case *ast.TemplateLiteral:
+		return self.evaluateTemplateLiteral(expr)
 	case *ast.Binding:
-		return self.evaluateBinding(expr)
+		return self.evaluateBinding(expr, false)
 	case *ast.Identifier:
 		return self.evaluateIdentifier(expr)
 	case *ast.ObjectLiteral:
@@ -32,6 +41,8 @@ func (self *Interpreter) evaluateExpression(expression ast.Expression) Value {
 		return self.evaluateThisExpression(expr)
 	case *ast.AssignExpression:
 		return self.evaluateAssignExpression(expr)
+	case *ast.ConditionalExpression:
+		return self.evaluateConditionalExpression(expr)
 	case *ast.BinaryExpression:
 		return self.evaluateBinaryExpression(expr)
 	case *ast.UnaryExpression:
@@ -50,11 +61,17 @@ func (self *Interpreter) evaluateSkip() Value {
 	return Const_Skip_Value
 }
 
-func (self *Interpreter) evaluateBreak() Value {
+func (self *Interpreter) evaluateBreak(label *ast.Identifier) Value {
+	if label != nil {
+		return Value{Break, label.Name}
+	}
 	return Const_Break_Value
 }
 
-func (self *Interpreter) evaluateContinue() Value {
+func (self *Interpreter) evaluateContinue(label *ast.Identifier) Value {
+	if label != nil {
+		return Value{Continue, label.Name}
+	}
 	return Const_Continue_Value
 }
 
@@ -87,14 +104,50 @@ func (self *Interpreter) evaluateStringLiteral(value any) Value {
 	return StringValue(value)
 }
 
-func (self *Interpreter) evaluateBinding(binding *ast.Binding) Value {
+func (self *Interpreter) evaluateTemplateLiteral(templateLiteral *ast.TemplateLiteral) Value {
+	evaluateSubstitutions := func() (values []Value) {
+		for _, expression := range templateLiteral.Expressions {
+			values = append(values, self.evaluateExpression(expression).flatResolve())
+		}
+		return
+	}
+	if templateLiteral.Tag != nil {
+		return self.evaluateCall(templateLiteral.Tag, func() []Value {
+			var cooked, raw []Value
+			for _, element := range templateLiteral.Elements {
+				cooked = append(cooked, self.evaluateStringLiteral(element.Parsed))
+				raw = append(raw, self.evaluateStringLiteral(element.Literal))
+			}
+			templateObject := BuiltinArrayObject(cooked)
+			templateObject.objectd().setProperty("raw", BuiltinArrayObject(raw))
+			return append([]Value{templateObject}, evaluateSubstitutions()...)
+		})
+	}
+	values := evaluateSubstitutions()
+	var builder strings.Builder
+	for i, element := range templateLiteral.Elements {
+		builder.WriteString(element.Parsed)
+		if i < len(values) {
+			builder.WriteString(values[i].string())
+		}
+	}
+	return self.evaluateStringLiteral(builder.String())
+}
+
+// evaluateBinding declares the target of binding in the current stash, as a
+// constant if constant is set.
+func (self *Interpreter) evaluateBinding(binding *ast.Binding, constant bool) Value {
 	targetValue := self.evaluateExpression(binding.Target)
 	targetRef := targetValue.referenced()
 	if self.runtime.getStash().contains(targetRef.getName()) {
 		self.panic("already defined: "+targetRef.getName(), binding.StartIndex())
 	}
 	initValue := self.evaluateExpression(binding.Initializer)
-	targetRef.setValue(initValue)
+	if constant {
+		self.runtime.getStash().defineConstant(targetRef.getName(), initValue)
+	} else {
+		self.runtime.getStash().define(targetRef.getName(), initValue)
+	}
 	return self.evaluateSkip()
 }
 
@@ -136,9 +189,7 @@ func (self *Interpreter) evaluateFunction(identifier *ast.Identifier, parameterL
 		return functionValue
 	}
 	globalFunction.name = identifier.Name
-	identifierValue := self.evaluateExpression(identifier)
-	identifierRef := identifierValue.referenced()
-	identifierRef.setValue(functionValue)
+	self.runtime.getStash().define(identifier.Name, functionValue)
 	return self.evaluateSkip()
 }
 
@@ -161,11 +212,27 @@ func (self *Interpreter) evaluateAssignExpression(assignExpression *ast.AssignEx
 	if assignExpression.Operator != token.ASSIGN {
 		rightValue = self.evaluateBinary(leftValue, assignExpression.Operator, rightValue)
 	}
-	leftRef := leftValue.referenced()
-	leftRef.setValue(rightValue)
+	self.assign(leftValue.referenced(), rightValue, assignExpression.StartIndex())
 	return self.evaluateSkip()
 }
 
+// assign stores value through ref, assigning to a constant panics with the
+// TypeError the VM throws.
+func (self *Interpreter) assign(ref Referenced, value Value, index file.Index) {
+	if stashRef, ok := ref.(StashReferenced); ok && stashRef.stash.isConstant(stashRef.name) {
+		self.panic(fmt.Sprintf("TypeError: Assignment to constant variable '%s'", stashRef.name), index)
+	}
+	ref.setValue(value)
+}
+
+func (self *Interpreter) evaluateConditionalExpression(conditionalExpression *ast.ConditionalExpression) Value {
+	testValue := self.evaluateExpression(conditionalExpression.Test)
+	if testValue.bool() {
+		return self.evaluateExpression(conditionalExpression.Consequent)
+	}
+	return self.evaluateExpression(conditionalExpression.Alternate)
+}
+
 func (self *Interpreter) evaluateBinaryExpression(binaryExpression *ast.BinaryExpression) Value {
 	left, operator, right, comparison := binaryExpression.Left, binaryExpression.Operator, binaryExpression.Right, binaryExpression.Comparison
 
@@ -234,50 +301,88 @@ func (self *Interpreter) evaluateBinary(leftValue Value, operator token.Token, r
 		return self.evaluateNumberLiteral(leftValue.float64() / rightValue.float64())
 	case token.REMAINDER:
 		return self.evaluateNumberLiteral(leftValue.int64() % rightValue.int64())
+	case token.EXPONENT:
+		base, baseIsInt := leftValue.getVal().(int64)
+		exponent, exponentIsInt := rightValue.getVal().(int64)
+		if baseIsInt && exponentIsInt && exponent >= 0 {
+			if result, ok := intPower(base, exponent); ok {
+				return self.evaluateNumberLiteral(result)
+			}
+		}
+		return self.evaluateNumberLiteral(math.Pow(leftValue.float64(), rightValue.float64()))
 	case token.AND_ARITHMETIC:
 		return self.evaluateNumberLiteral(leftValue.int64() & rightValue.int64())
 	case token.OR_ARITHMETIC:
 		return self.evaluateNumberLiteral(leftValue.int64() | rightValue.int64())
+	case token.XOR_ARITHMETIC:
+		return self.evaluateNumberLiteral(leftValue.int64() ^ rightValue.int64())
+	case token.SHIFT_LEFT:
+		return self.evaluateNumberLiteral(leftValue.int64() << (uint64(rightValue.int64()) & 63))
+	case token.SHIFT_RIGHT:
+		return self.evaluateNumberLiteral(leftValue.int64() >> (uint64(rightValue.int64()) & 63))
+	case token.UNSIGNED_SHIFT_RIGHT:
+		return self.evaluateNumberLiteral(int64(uint64(leftValue.int64()) >> (uint64(rightValue.int64()) & 63)))
 	}
 	return self.panic("Unsupported operator: "+operator.String(), -1)
 }
 
+// intPower returns base ** exponent like the VM does for ints, it fails if the
+// result overflows int64.
+func intPower(base int64, exponent int64) (int64, bool) {
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if mulOverflows(result, base) {
+				return 0, false
+			}
+			result *= base
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			if mulOverflows(base, base) {
+				return 0, false
+			}
+			base *= base
+		}
+	}
+	return result, true
+}
+
+func mulOverflows(a int64, b int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	result := a * b
+	return result/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64
+}
+
 func (self *Interpreter) evaluateUnaryExpression(unaryExpression *ast.UnaryExpression) Value {
 	operandValue := self.evaluateExpression(unaryExpression.Operand)
 
-	if operandValue.isReferenced() {
-		operandRef := operandValue.referenced()
-		switch unaryExpression.Operator {
-		case token.NOT:
-			operandRef.setValue(self.evaluateBooleanLiteral(!operandValue.bool()))
-			return operandValue
-		case token.ADDITION:
-			val := operandValue.float64()
-			if val < 0 {
-				val = -val
-			}
-			operandRef.setValue(self.evaluateNumberLiteral(val))
-			return operandValue
-		case token.SUBTRACT:
-			operandRef.setValue(self.evaluateNumberLiteral(-operandValue.float64()))
-			return operandValue
-		case token.INCREMENT:
-			operandRef.setValue(self.evaluateNumberLiteral(operandValue.float64() + 1))
-			return operandValue
-		case token.DECREMENT:
-			operandRef.setValue(self.evaluateNumberLiteral(operandValue.float64() - 1))
-			return operandValue
+	if unaryExpression.Operator == token.BITWISE_NOT {
+		return self.evaluateNumberLiteral(^operandValue.int64())
+	}
+
+	// Only ++ and -- write back to their operand.
+	switch unaryExpression.Operator {
+	case token.NOT:
+		return self.evaluateBooleanLiteral(!operandValue.bool())
+	case token.ADDITION:
+		val := operandValue.float64()
+		if val < 0 {
+			val = -val
 		}
-	} else {
-		switch unaryExpression.Operator {
-		case token.ADDITION:
-			val := operandValue.float64()
-			if val < 0 {
-				val = -val
+		return self.evaluateNumberLiteral(val)
+	case token.SUBTRACT:
+		return self.evaluateNumberLiteral(-operandValue.float64())
+	case token.INCREMENT, token.DECREMENT:
+		if operandValue.isReferenced() {
+			delta := 1.0
+			if unaryExpression.Operator == token.DECREMENT {
+				delta = -1
 			}
-			return self.evaluateNumberLiteral(val)
-		case token.SUBTRACT:
-			return self.evaluateNumberLiteral(-operandValue.float64())
+			self.assign(operandValue.referenced(), self.evaluateNumberLiteral(operandValue.float64()+delta), unaryExpression.StartIndex())
+			return operandValue
 		}
 	}
 
@@ -285,7 +390,17 @@ func (self *Interpreter) evaluateUnaryExpression(unaryExpression *ast.UnaryExpre
 }
 
 func (self *Interpreter) evaluateCallExpression(callExpression *ast.CallExpression) Value {
-	calleeValue := self.evaluateExpression(callExpression.Callee)
+	return self.evaluateCall(callExpression.Callee, func() []Value {
+		var arguments []Value
+		for _, argument := range callExpression.Arguments {
+			arguments = append(arguments, self.evaluateExpression(argument))
+		}
+		return arguments
+	})
+}
+
+func (self *Interpreter) evaluateCall(calleeExpression ast.Expression, evaluateArguments func() []Value) Value {
+	calleeValue := self.evaluateExpression(calleeExpression)
 	var calleeRef Referenced
 	if calleeValue.getValueType() == Function {
 		calleeRef = AnonymousReferenced{
@@ -296,13 +411,10 @@ func (self *Interpreter) evaluateCallExpression(callExpression *ast.CallExpressi
 	}
 	calleeValue = calleeValue.flatResolve()
 	if !calleeValue.isFunction() {
-		self.panic(fmt.Sprintf("%s is not a function", calleeRef.getName()), callExpression.StartIndex())
+		self.panic(fmt.Sprintf("%s is not a function", calleeRef.getName()), calleeExpression.StartIndex())
 	}
 	function := calleeValue.functiond()
-	var arguments []Value
-	for _, argument := range callExpression.Arguments {
-		arguments = append(arguments, self.evaluateExpression(argument))
-	}
+	arguments := evaluateArguments()
 	var this Objectd
 	var callee string
 	if calleeRef.getType() == PropertyReferencedType {
@@ -321,15 +433,30 @@ func (self *Interpreter) evaluateCallExpression(callExpression *ast.CallExpressi
 	return resultValue
 }
 
+// callMethod calls the method name of object with object as this.
+func (self *Interpreter) callMethod(object Objectd, name string, index file.Index) Value {
+	method := object.getProperty(name)
+	if !method.isFunction() {
+		self.panic(fmt.Sprintf("%s is not a function", name), index)
+	}
+	self.runtime.openScope(object, name)
+	defer self.runtime.closeScope()
+	resultValue := method.functiond().call()
+	if resultValue.isReturn() {
+		resultValue = resultValue.ofValue()
+	}
+	return resultValue.flatResolve()
+}
+
 func (self *Interpreter) evaluateCallFunction(parameterList *ast.ParameterList, body ast.Statement, arguments ...Value) Value {
 	argsLength := len(arguments)
 	for index, binding := range parameterList.List {
 		targetValue := self.evaluateExpression(binding.Target)
 		targetRef := targetValue.referenced()
 		if argsLength > index {
-			targetRef.setValue(arguments[index])
+			self.runtime.getStash().define(targetRef.getName(), arguments[index])
 		} else if binding.Initializer != nil {
-			targetRef.setValue(self.evaluateExpression(binding.Initializer))
+			self.runtime.getStash().define(targetRef.getName(), self.evaluateExpression(binding.Initializer))
 		}
 	}
 	return self.evaluateStatement(body)
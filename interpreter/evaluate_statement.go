@@ -2,7 +2,15 @@ package interpreter
 
 import (
 	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"sort"
+)
+
+const (
+	Iterator_Method         = "iterator"
+	Iterator_HasNext_Method = "hasNext"
+	Iterator_Next_Method    = "next"
 )
 
 func (self *Interpreter) evaluateProgramBody(listStatement []ast.Statement) Value {
@@ -31,6 +39,8 @@ func (self *Interpreter) evaluateStatement(statement ast.Statement) Value {
 		return self.evaluateBreakStatement(st)
 	case *ast.ContinueStatement:
 		return self.evaluateContinueStatement(st)
+	case *ast.LabelledStatement:
+		return self.evaluateLabelledStatement(st)
 	case *ast.ReturnStatement:
 		return self.evaluateReturnStatement(st)
 	case *ast.IfStatement:
@@ -39,6 +49,14 @@ func (self *Interpreter) evaluateStatement(statement ast.Statement) Value {
 		return self.evaluateSwitchStatement(st)
 	case *ast.ForStatement:
 		return self.evaluateForStatement(st)
+	case *ast.ForInStatement:
+		return self.evaluateForInStatement(st)
+	case *ast.ForOfStatement:
+		return self.evaluateForOfStatement(st)
+	case *ast.WhileStatement:
+		return self.evaluateWhileStatement(st)
+	case *ast.DoWhileStatement:
+		return self.evaluateDoWhileStatement(st)
 	case *ast.FunStatement:
 		return self.evaluateFunStatement(st)
 	case *ast.ExpressionStatement:
@@ -60,17 +78,52 @@ func (self *Interpreter) evaluateBlockStatement(blockStatement *ast.BlockStateme
 
 func (self *Interpreter) evaluateVarStatement(varStatement *ast.VarStatement) Value {
 	for _, binding := range varStatement.List {
-		self.evaluateBinding(binding)
+		self.evaluateBinding(binding, varStatement.Token == token.CONST)
 	}
 	return self.evaluateSkip()
 }
 
 func (self *Interpreter) evaluateBreakStatement(breakStatement *ast.BreakStatement) Value {
-	return self.evaluateBreak()
+	return self.evaluateBreak(breakStatement.Label)
 }
 
 func (self *Interpreter) evaluateContinueStatement(continueStatement *ast.ContinueStatement) Value {
-	return self.evaluateContinue()
+	return self.evaluateContinue(continueStatement.Label)
+}
+
+func (self *Interpreter) evaluateLabelledStatement(labelledStatement *ast.LabelledStatement) Value {
+	switch labelledStatement.Statement.(type) {
+	case *ast.ForStatement, *ast.ForInStatement, *ast.ForOfStatement, *ast.WhileStatement, *ast.DoWhileStatement:
+		self.label = labelledStatement.Label.Name
+	}
+	value := self.evaluateStatement(labelledStatement.Statement)
+	if value.isBreak() && value.value == labelledStatement.Label.Name {
+		return self.evaluateSkip()
+	}
+	return value
+}
+
+func (self *Interpreter) claimLabel() string {
+	label := self.label
+	self.label = ""
+	return label
+}
+
+// isLoopBreak reports whether value ends the enclosing loop, a labelled break
+// is passed on to its labelled statement instead.
+func (self *Interpreter) isLoopBreak(value Value) bool {
+	return value.isBreak() && value.value == nil
+}
+
+// isLoopContinue reports whether value continues the loop labelled label.
+func (self *Interpreter) isLoopContinue(value Value, label string) bool {
+	return value.isContinue() && (value.value == nil || value.value == label)
+}
+
+// isLoopExit reports whether value leaves the loop for an outer statement, a
+// return or a break or continue of an outer label.
+func (self *Interpreter) isLoopExit(value Value) bool {
+	return value.isReturn() || value.isBreak() || value.isContinue()
 }
 
 func (self *Interpreter) evaluateReturnStatement(returnStatement *ast.ReturnStatement) Value {
@@ -111,7 +164,12 @@ func (self *Interpreter) evaluateSwitchStatement(switchStatement *ast.SwitchStat
 	return self.evaluateStatement(consequent)
 }
 
+// evaluateForStatement declares the initializer in a stash of the loop, so a
+// nested loop can declare it again on every run.
 func (self *Interpreter) evaluateForStatement(forStatement *ast.ForStatement) Value {
+	label := self.claimLabel()
+	self.runtime.openStash()
+	defer self.runtime.closeStash()
 	if forStatement.Initializer != nil {
 		self.evaluateStatement(forStatement.Initializer)
 	}
@@ -122,12 +180,12 @@ func (self *Interpreter) evaluateForStatement(forStatement *ast.ForStatement) Va
 				break
 			}
 		}
-		value := self.evaluateStatement(forStatement.Body)
-		if value.isBreak() {
+		value := self.evaluateLoopBody(forStatement.Body)
+		if self.isLoopBreak(value) {
 			break
-		} else if value.isContinue() {
+		} else if self.isLoopContinue(value, label) {
 			// none
-		} else if !value.isSkip() {
+		} else if self.isLoopExit(value) {
 			return value
 		}
 		if forStatement.Update != nil {
@@ -137,6 +195,161 @@ func (self *Interpreter) evaluateForStatement(forStatement *ast.ForStatement) Va
 	return self.evaluateSkip()
 }
 
+func (self *Interpreter) evaluateForInStatement(forInStatement *ast.ForInStatement) Value {
+	label := self.claimLabel()
+	object := self.evaluateIterable(forInStatement.Source)
+	return self.evaluateIteration(label, self.entries(object), forInStatement.Token == token.CONST, forInStatement.Key, forInStatement.Value, forInStatement.Body)
+}
+
+// evaluateForOfStatement prefers the iterator returned by an iterator() method
+// of the source, only for-in enumerates the entries of such an object.
+func (self *Interpreter) evaluateForOfStatement(forOfStatement *ast.ForOfStatement) Value {
+	label := self.claimLabel()
+	object := self.evaluateIterable(forOfStatement.Source)
+	next := self.entries(object)
+	if method := object.getProperty(Iterator_Method); method.isFunction() {
+		next = self.protocolEntries(object, forOfStatement.Source.StartIndex())
+	}
+	return self.evaluateIteration(label, next, forOfStatement.Token == token.CONST, nil, forOfStatement.Value, forOfStatement.Body)
+}
+
+func (self *Interpreter) evaluateIterable(source ast.Expression) Objectd {
+	sourceValue := self.evaluateExpression(source)
+	if !sourceValue.isObject() {
+		self.panic(sourceValue.ofLiteral()+" is not iterable", source.StartIndex())
+	}
+	return sourceValue.objectd()
+}
+
+// entries yields the elements of an array or the properties of an object in
+// key order.
+func (self *Interpreter) entries(object Objectd) func() (Value, Value, bool) {
+	var keys, values []Value
+	if builtinArray, ok := object.classObject.(BuiltinArray); ok {
+		for index, element := range builtinArray.values {
+			keys = append(keys, NumberValue(int64(index)))
+			values = append(values, element)
+		}
+	} else {
+		names := make([]string, 0, len(object.propertys))
+		for name := range object.propertys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			keys = append(keys, StringValue(name))
+			values = append(values, object.propertys[name])
+		}
+	}
+	index := 0
+	return func() (Value, Value, bool) {
+		if index >= len(keys) {
+			return Value{}, Value{}, false
+		}
+		index++
+		return keys[index-1], values[index-1], true
+	}
+}
+
+// protocolEntries calls the iterator() method of object and yields the values
+// of the returned iterator through its hasNext() and next() methods.
+func (self *Interpreter) protocolEntries(object Objectd, index file.Index) func() (Value, Value, bool) {
+	iteratorValue := self.callMethod(object, Iterator_Method, index)
+	if !iteratorValue.isObject() {
+		self.panic("Result of the iterator method is not an object", index)
+	}
+	iterator, count := iteratorValue.objectd(), int64(0)
+	return func() (Value, Value, bool) {
+		if hasNext := self.callMethod(iterator, Iterator_HasNext_Method, index); !hasNext.bool() {
+			return Value{}, Value{}, false
+		}
+		count++
+		return NumberValue(count - 1), self.callMethod(iterator, Iterator_Next_Method, index), true
+	}
+}
+
+func (self *Interpreter) evaluateIteration(label string, next func() (Value, Value, bool), constant bool, key *ast.Identifier, value *ast.Identifier, body ast.Statement) Value {
+	for {
+		keyValue, valueValue, ok := next()
+		if !ok {
+			break
+		}
+		result := self.evaluateIterationBody(body, constant, key, keyValue, value, valueValue)
+		if self.isLoopBreak(result) {
+			break
+		} else if self.isLoopContinue(result, label) {
+			// none
+		} else if self.isLoopExit(result) {
+			return result
+		}
+	}
+	return self.evaluateSkip()
+}
+
+// evaluateLoopBody evaluates one iteration of a loop body in a stash of its
+// own, so declarations in the body do not clash with the previous iteration.
+func (self *Interpreter) evaluateLoopBody(body ast.Statement) Value {
+	self.runtime.openStash()
+	defer self.runtime.closeStash()
+	return self.evaluateStatement(body)
+}
+
+// evaluateIterationBody binds the entry in a stash of its own, so every
+// iteration starts from fresh bindings.
+func (self *Interpreter) evaluateIterationBody(body ast.Statement, constant bool, key *ast.Identifier, keyValue Value, value *ast.Identifier, valueValue Value) Value {
+	self.runtime.openStash()
+	defer self.runtime.closeStash()
+	define := self.runtime.getStash().define
+	if constant {
+		define = self.runtime.getStash().defineConstant
+	}
+	if key != nil {
+		define(key.Name, keyValue)
+	}
+	if value != nil {
+		define(value.Name, valueValue)
+	}
+	return self.evaluateStatement(body)
+}
+
+func (self *Interpreter) evaluateWhileStatement(whileStatement *ast.WhileStatement) Value {
+	label := self.claimLabel()
+	for {
+		conditionValue := self.evaluateExpression(whileStatement.Condition)
+		if !conditionValue.bool() {
+			break
+		}
+		value := self.evaluateLoopBody(whileStatement.Body)
+		if self.isLoopBreak(value) {
+			break
+		} else if self.isLoopContinue(value, label) {
+			// none
+		} else if self.isLoopExit(value) {
+			return value
+		}
+	}
+	return self.evaluateSkip()
+}
+
+func (self *Interpreter) evaluateDoWhileStatement(doWhileStatement *ast.DoWhileStatement) Value {
+	label := self.claimLabel()
+	for {
+		value := self.evaluateLoopBody(doWhileStatement.Body)
+		if self.isLoopBreak(value) {
+			break
+		} else if self.isLoopContinue(value, label) {
+			// none
+		} else if self.isLoopExit(value) {
+			return value
+		}
+		conditionValue := self.evaluateExpression(doWhileStatement.Condition)
+		if !conditionValue.bool() {
+			break
+		}
+	}
+	return self.evaluateSkip()
+}
+
 func (self *Interpreter) evaluateFunStatement(funStatement *ast.FunStatement) Value {
 	return self.evaluateExpression(funStatement.FunLiteral)
 }
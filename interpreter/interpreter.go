@@ -10,6 +10,8 @@ import (
 type Interpreter struct {
 	runtime *Runtime
 	file    *file.File
+	// label is the pending label of a labelled loop, claimed by the loop when it starts.
+	label string
 }
 
 func CreateInterpreter() *Interpreter {
@@ -42,5 +44,4 @@ func (self *Interpreter) panic(msg string, index file.Index) Value {
 		trace = fmt.Sprintf(" at %s (%s %d:%d)", self.runtime.scope.callee, position.FileName, position.Line, position.Column)
 	}
 	panic(fmt.Sprintf("%s\n\t%s", msg, trace))
-	return Const_Skip_Value
 }
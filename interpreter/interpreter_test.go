@@ -2,7 +2,10 @@ package interpreter
 
 import (
 	"fmt"
+	"github.com/istrangers/demolanguage/vm"
+	"math"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -12,3 +15,112 @@ func TestInterpreter(t *testing.T) {
 	value := interpreter.run("example.dl", string(content))
 	println(fmt.Sprintf("%v", value.getVal()))
 }
+
+// runScriptTests runs each script in a new interpreter and compares the string
+// of its completion value.
+func runScriptTests(t *testing.T, tests map[string]string) {
+	t.Helper()
+	for script, expected := range tests {
+		if actual := CreateInterpreter().run("", script); actual.string() != expected {
+			t.Errorf("%q: got %s, want %s", script, actual.string(), expected)
+		}
+	}
+}
+
+func TestExponentiation(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"2 ** 10":               "1024",
+		"2 ** 3 ** 2":           "512",
+		"-2 ** 2":               "-4",
+		"(-2) ** 3":             "-8",
+		"2 ** -1":               "0.5",
+		"var a = 3\na **= 2\na": "9",
+	})
+	// Integer powers stay integers as in the VM, unless they overflow.
+	if value := CreateInterpreter().run("", "2 ** 3"); value.getVal() != int64(8) {
+		t.Errorf("2 ** 3 = %T %v", value.getVal(), value.getVal())
+	}
+	if value := CreateInterpreter().run("", "2 ** 63"); value.getVal() != math.Pow(2, 63) {
+		t.Errorf("2 ** 63 = %T %v", value.getVal(), value.getVal())
+	}
+}
+
+func TestIteration(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var s = \"\"\nfor var k, v in {b: 2, a: 1} {\ns += k + v\n}\ns":                  "a1b2",
+		"var s = \"\"\nfor var i, v in [\"x\", \"y\"] {\ns += i + v\n}\ns":                "0x1y",
+		"var s = 0\nfor var v of [1, 2, 3] {\nvar d = v * 10\ns += d\n}\ns":               "60",
+		"var s = \"\"\nfor var v of [1, 2] {\nfor var w of [3, 4] {\ns += v * w\n}\n}\ns": "3468",
+		"var r = {n: 3, iterator: fun() { return {i: 0, n: this.n, hasNext: fun() { return this.i < this.n }, next: fun() { this.i = this.i + 1\nreturn this.i }} }}\nvar s = \"\"\nfor var x of r {\ns += x\n}\ns": "123",
+		"var r = {n: 3, iterator: fun() { return {} }}\nvar s = \"\"\nfor var k in r {\ns += k + \",\"\n}\ns":                                                                                                       "iterator,n,",
+	})
+}
+
+func TestLoops(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var s = 0\nfor var a = 0; a < 3; a++ {\nfor var b = 0; b < 2; b++ {\ns += 1\n}\n}\ns":  "6",
+		"var s = 0\nvar i = 0\nwhile i < 3 {\nvar d = i * 2\ns += d\ni++\n}\ns":                 "6",
+		"var i = 0\nwhile i < 3 {\ni++\n}\ni":                                                   "3",
+		"fun f() {\nfor var x of [1, 2, 3] {\nif x == 2 { return x * 10 }\n}\nreturn 0\n}\nf()": "20",
+		"var n = 0\ndo {\nvar d = 1\nn += d\n} while n < 3\nn":                                  "3",
+		"var found = \"\"\nouter: for var i = 0; i < 5; i++ {\nfor var j = 0; j < 5; j++ {\nif j > i { continue outer }\nif i * j == 6 {\nfound = \"\" + i + j\nbreak outer\n}\n}\n}\nfound": "32",
+		"var s = \"\"\nrows: for var r of [[1, 2], [3, 4], [5, 6]] {\nfor var c of r {\nif c == 4 { continue rows }\nif c == 6 { break rows }\ns += c\n}\ns += \"|\"\n}\ns":                  "12|35",
+		"var w = 0\nloop: while true {\ndo {\nw++\nif w > 3 { break loop }\ncontinue loop\n} while true\n}\nw":                                                                               "4",
+	})
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var a = 7\na += 3\na -= 2\na *= 3\na":           "24",
+		"var a = 7\na %= 4\na":                           "3",
+		"var a = 12\na &= 10\na |= 1\na ^= 4\na":         "13",
+		"var a = 1\na <<= 4\na >>= 2\na":                 "4",
+		"var s = \"a\"\ns += 1\ns":                       "a1",
+		"var o = {n: 1}\no.n += 2\no.n *= 5\no.n":        "15",
+		"var s = 0\nfor var x of [1, 2] {\ns += x\n}\ns": "3",
+	})
+}
+
+func TestBigIntLiteral(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "BigInt literals are not supported") {
+			t.Errorf("expected a BigInt literal error, got %v", err)
+		}
+	}()
+	CreateInterpreter().run("", "var a = 1n\na + 1")
+}
+
+func TestConstant(t *testing.T) {
+	tests := map[string]string{
+		"const a = 2\nvar b = -a\nb + a * a":                             "2",
+		"const a = 1\nfun f() {\nvar a = 2\na = 3\nreturn a\n}\nf() + a": "4",
+		"var s = 0\nfor const x of [1, 2] {\ns += x\n}\ns":               "3",
+	}
+	runScriptTests(t, tests)
+	for script, expected := range tests {
+		if result, err := vm.CreateVM().RunScript(script); err != nil || vm.Literal(result) != expected {
+			t.Errorf("%q: vm got %v, %v, want %s", script, result, err, expected)
+		}
+	}
+	for _, script := range []string{
+		"const a = 1\na = 2\na",
+		"const a = 1\na += 2\na",
+		"const a = 1\na++\na",
+		"const a = 1\nif true {\na = 2\n}\na",
+		"for const x of [1] {\nx = 2\n}",
+	} {
+		_, err := vm.CreateVM().RunScript(script)
+		if err == nil || !strings.Contains(err.Error(), "Assignment to constant variable") {
+			t.Errorf("%q: vm error %v", script, err)
+		}
+		func() {
+			defer func() {
+				recovered := recover()
+				if recovered == nil || !strings.Contains(fmt.Sprint(recovered), "TypeError: Assignment to constant variable") {
+					t.Errorf("%q: interpreter error %v", script, recovered)
+				}
+			}()
+			CreateInterpreter().run("", script)
+		}()
+	}
+}
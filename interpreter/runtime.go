@@ -35,6 +35,19 @@ func (self *Runtime) closeScope() {
 	self.scope = self.scope.outer
 }
 
+// openStash nests a stash in the current scope for the bindings of a block.
+func (self *Runtime) openStash() {
+	self.scope.stash = &Stash{
+		runtime:      self,
+		outer:        self.scope.stash,
+		valueMapping: make(map[string]Value),
+	}
+}
+
+func (self *Runtime) closeStash() {
+	self.scope.stash = self.scope.stash.outer
+}
+
 func (self *Runtime) getStash() *Stash {
 	stash := self.scope.stash
 	return stash
@@ -4,12 +4,45 @@ type Stash struct {
 	runtime      *Runtime
 	outer        *Stash
 	valueMapping map[string]Value
+	constants    map[string]bool
 }
 
+// setValue assigns name in the nearest stash that declares it, an undeclared
+// name is created in this stash.
 func (self *Stash) setValue(name string, value Value) {
+	for stash := self; stash != nil; stash = stash.outer {
+		if _, exists := stash.valueMapping[name]; exists {
+			stash.valueMapping[name] = value
+			return
+		}
+	}
+	self.valueMapping[name] = value
+}
+
+// define declares name in this stash, shadowing any outer declaration.
+func (self *Stash) define(name string, value Value) {
 	self.valueMapping[name] = value
 }
 
+// defineConstant declares name as a constant in this stash.
+func (self *Stash) defineConstant(name string, value Value) {
+	self.define(name, value)
+	if self.constants == nil {
+		self.constants = make(map[string]bool)
+	}
+	self.constants[name] = true
+}
+
+// isConstant reports whether the nearest declaration of name is a constant.
+func (self *Stash) isConstant(name string) bool {
+	for stash := self; stash != nil; stash = stash.outer {
+		if _, exists := stash.valueMapping[name]; exists {
+			return stash.constants[name]
+		}
+	}
+	return false
+}
+
 func (self *Stash) getValue(name string) Value {
 	stash := self
 	for stash != nil {
@@ -2,6 +2,7 @@ package interpreter
 
 import (
 	"math"
+	"math/big"
 	"regexp"
 	"strconv"
 )
@@ -176,6 +177,8 @@ func (self *Value) int64() int64 {
 		return int64(v)
 	case int64:
 		return v
+	case *big.Int:
+		return v.Int64()
 	}
 	panic("Unable to convert to int64")
 }
@@ -204,6 +207,9 @@ func (self *Value) float64() float64 {
 		return float64(v)
 	case float64:
 		return v
+	case *big.Int:
+		value, _ := new(big.Float).SetInt(v).Float64()
+		return value
 	}
 	panic("Unable to convert to float64")
 }
@@ -247,6 +253,8 @@ func (self *Value) string() string {
 		return floatToString(v, 32)
 	case string:
 		return v
+	case *big.Int:
+		return v.String()
 	}
 	panic("Unable to convert to string")
 }
@@ -1,5 +1,296 @@
 package main
 
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/parser"
+	"github.com/istrangers/demolanguage/printer"
+	"github.com/istrangers/demolanguage/repl"
+	"github.com/istrangers/demolanguage/vm"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	exitOK          = 0
+	exitException   = 1
+	exitSyntaxError = 2
+	exitUsage       = 3
+)
+
+const usage = `Usage: demolanguage <command> [arguments]
+
+Commands:
+  run [file.dl|-]      compile and run a script, "-" or no file reads stdin
+  eval -e <code>       compile and run code given on the command line
+  repl                 start an interactive session
+  disasm [file.dl|-]   print the compiled instructions of a script
+  check [file.dl|-]    report syntax errors without running the script
+  parse -json [file.dl|-]
+                       print the syntax tree of a script as JSON
+  fmt [-w] [-d] [file.dl ...]
+                       print scripts in canonical form, -w rewrites the
+                       files and -d prints a diff instead
+  help                 print this message
+
+"demolanguage <file.dl>" is shorthand for "demolanguage run <file.dl>".
+Without arguments a piped stdin is run, otherwise the repl is started.
+
+Exit status is 0 on success, 1 on an uncaught exception, 2 on a syntax
+error and 3 on a usage or I/O error.
+`
+
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
 func main() {
+	cli := &cli{os.Stdin, os.Stdout, os.Stderr}
+	args := os.Args[1:]
+	if len(args) == 0 && !isTerminal(os.Stdin) {
+		args = []string{"run"}
+	}
+	os.Exit(cli.run(args))
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (self *cli) run(args []string) int {
+	if len(args) == 0 {
+		return self.repl(args)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "run":
+		return self.runScript(args)
+	case "eval":
+		return self.eval(args)
+	case "repl":
+		return self.repl(args)
+	case "disasm":
+		return self.disasm(args)
+	case "check":
+		return self.check(args)
+	case "parse":
+		return self.parse(args)
+	case "fmt":
+		return self.format(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(self.stdout, usage)
+		return exitOK
+	}
+	if strings.HasSuffix(command, ".dl") {
+		return self.runScript(append([]string{command}, args...))
+	}
+	return self.usageError("unknown command %q", command)
+}
+
+func (self *cli) usageError(format string, args ...any) int {
+	fmt.Fprintf(self.stderr, "demolanguage: "+format+"\n\n", args...)
+	fmt.Fprint(self.stderr, usage)
+	return exitUsage
+}
+
+func (self *cli) flagSet(name string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.SetOutput(self.stderr)
+	return flagSet
+}
+
+func (self *cli) readSource(args []string) (string, string, int) {
+	if len(args) > 1 {
+		return "", "", self.usageError("too many arguments: %s", strings.Join(args[1:], " "))
+	}
+	var content []byte
+	var err error
+	fileName := "<stdin>"
+	if len(args) == 0 || args[0] == "-" {
+		content, err = io.ReadAll(self.stdin)
+	} else {
+		fileName = args[0]
+		content, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		fmt.Fprintf(self.stderr, "demolanguage: %v\n", err)
+		return "", "", exitUsage
+	}
+	return fileName, string(content), exitOK
+}
+
+func (self *cli) compile(fileName string, content string) (*vm.Program, int) {
+	program, err := vm.Compile(fileName, content)
+	if err != nil {
+		fmt.Fprintln(self.stderr, err)
+		return nil, exitSyntaxError
+	}
+	return program, exitOK
+}
+
+func (self *cli) execute(program *vm.Program, printResult bool) int {
+	result, err := vm.CreateVM().RunProgram(program)
+	if err != nil {
+		fmt.Fprintln(self.stderr, err)
+		return exitException
+	}
+	if printResult && result != nil {
+		fmt.Fprintln(self.stdout, vm.Literal(result))
+	}
+	return exitOK
+}
+
+func (self *cli) runScript(args []string) int {
+	fileName, content, code := self.readSource(args)
+	if code != exitOK {
+		return code
+	}
+	program, code := self.compile(fileName, content)
+	if code != exitOK {
+		return code
+	}
+	return self.execute(program, false)
+}
+
+func (self *cli) eval(args []string) int {
+	flagSet := self.flagSet("eval")
+	code := flagSet.String("e", "", "code to evaluate")
+	if err := flagSet.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *code == "" && flagSet.NArg() > 0 {
+		*code = strings.Join(flagSet.Args(), " ")
+	}
+	program, exitCode := self.compile("<eval>", *code)
+	if exitCode != exitOK {
+		return exitCode
+	}
+	return self.execute(program, true)
+}
+
+func (self *cli) repl(args []string) int {
+	if len(args) > 0 {
+		return self.usageError("repl takes no arguments")
+	}
+	repl.Start(self.stdin, self.stdout)
+	return exitOK
+}
+
+func (self *cli) disasm(args []string) int {
+	fileName, content, code := self.readSource(args)
+	if code != exitOK {
+		return code
+	}
+	program, code := self.compile(fileName, content)
+	if code != exitOK {
+		return code
+	}
+	stdout, ok := self.stdout.(*os.File)
+	program.DumpInstructions(ok && isTerminal(stdout), func(format string, args ...interface{}) {
+		fmt.Fprintf(self.stdout, format+"\n", args...)
+	})
+	return exitOK
+}
+
+func (self *cli) check(args []string) int {
+	fileName, content, code := self.readSource(args)
+	if code != exitOK {
+		return code
+	}
+	_, code = self.compile(fileName, content)
+	return code
+}
+
+func (self *cli) parse(args []string) int {
+	flagSet := self.flagSet("parse")
+	toJSON := flagSet.Bool("json", false, "print the syntax tree as JSON")
+	if err := flagSet.Parse(args); err != nil {
+		return exitUsage
+	}
+	if !*toJSON {
+		return self.usageError("parse needs -json, the only output format")
+	}
+	fileName, content, code := self.readSource(flagSet.Args())
+	if code != exitOK {
+		return code
+	}
+	program, err := parser.CreateParser(1, fileName, content, false, true).Parse()
+	if err != nil {
+		fmt.Fprintln(self.stderr, err)
+		return exitSyntaxError
+	}
+	data, err := ast.ToJSON(program)
+	if err == nil {
+		var buffer bytes.Buffer
+		if err = json.Indent(&buffer, data, "", "  "); err == nil {
+			buffer.WriteByte('\n')
+			_, err = buffer.WriteTo(self.stdout)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(self.stderr, "demolanguage: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func (self *cli) format(args []string) int {
+	flagSet := self.flagSet("fmt")
+	write := flagSet.Bool("w", false, "write the result to the file instead of stdout")
+	diff := flagSet.Bool("d", false, "print a diff instead of the result")
+	if err := flagSet.Parse(args); err != nil {
+		return exitUsage
+	}
+	fileNames := flagSet.Args()
+	if len(fileNames) == 0 {
+		fileNames = []string{"-"}
+	}
+	exitCode := exitOK
+	for _, fileName := range fileNames {
+		if code := self.formatFile(fileName, *write, *diff); code > exitCode {
+			exitCode = code
+		}
+	}
+	return exitCode
+}
 
+func (self *cli) formatFile(fileName string, write bool, diff bool) int {
+	if write && fileName == "-" {
+		return self.usageError("cannot use -w with stdin")
+	}
+	fileName, content, code := self.readSource([]string{fileName})
+	if code != exitOK {
+		return code
+	}
+	result, err := printer.Format(fileName, content)
+	if err != nil {
+		fmt.Fprintln(self.stderr, err)
+		return exitSyntaxError
+	}
+	if diff && result != content {
+		fmt.Fprint(self.stdout, unifiedDiff(fileName, content, result))
+	}
+	if write {
+		if result == content {
+			return exitOK
+		}
+		info, err := os.Stat(fileName)
+		if err == nil {
+			err = os.WriteFile(fileName, []byte(result), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(self.stderr, "demolanguage: %v\n", err)
+			return exitUsage
+		}
+	} else if !diff {
+		fmt.Fprint(self.stdout, result)
+	}
+	return exitOK
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCli(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	cli := &cli{strings.NewReader(stdin), &stdout, &stderr}
+	code := cli.run(args)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCliExitCode(t *testing.T) {
+	tests := []struct {
+		stdin string
+		args  []string
+		code  int
+	}{
+		{"", []string{"run", "example/vm_example.dl"}, exitOK},
+		{"", []string{"eval", "-e", "1 + 2"}, exitOK},
+		{"var a = 1", []string{"run", "-"}, exitOK},
+		{"var a = ", []string{"run"}, exitSyntaxError},
+		{"", []string{"check", "example/example.dl"}, exitOK},
+		{"throw {value: 1}", []string{"run"}, exitException},
+		{"", []string{"run", "example/not_exists.dl"}, exitUsage},
+		{"", []string{"unknown"}, exitUsage},
+		{"", []string{"fmt", "example/example.dl"}, exitOK},
+		{"var a = ", []string{"fmt"}, exitSyntaxError},
+		{"var a = 1", []string{"fmt", "-w", "-"}, exitUsage},
+		{"var a = 1", []string{"parse", "--json"}, exitOK},
+		{"var a = ", []string{"parse", "--json"}, exitSyntaxError},
+		{"var a = 1", []string{"parse"}, exitUsage},
+	}
+	for _, test := range tests {
+		code, _, stderr := runCli(test.stdin, test.args...)
+		if code != test.code {
+			t.Errorf("%v: exit code %d, want %d (%s)", test.args, code, test.code, stderr)
+		}
+	}
+}
+
+func TestCliEval(t *testing.T) {
+	tests := map[string]string{
+		"var a = 40\na + 2":         "42\n",
+		"var a = [1, \"b\", 3n]\na": "[1,\"b\",3n]\n",
+		"Error(\"x\")":              "Error: x\n",
+		"var a = [1]\na.add(a)\na":  "[1,[Circular]]\n",
+		"var o = {}\no.self = o\no": "{self: [Circular]}\n",
+	}
+	for code, expected := range tests {
+		if _, stdout, _ := runCli("", "eval", "-e", code); stdout != expected {
+			t.Errorf("%q: eval output %q, want %q", code, stdout, expected)
+		}
+	}
+}
+
+func TestCliDisasm(t *testing.T) {
+	_, stdout, _ := runCli("var a = 1", "disasm")
+	if !strings.Contains(stdout, "InitVar") || strings.Contains(stdout, "\033") {
+		t.Errorf("disasm output %q", stdout)
+	}
+}
+
+func TestCliRepl(t *testing.T) {
+	_, stdout, _ := runCli("var a = 1\nfun f() { throw Error(\"x\") }\nf()\n", "repl")
+	if !strings.Contains(stdout, "at f (<repl>:2:11)\n\tat <repl>:3:1") {
+		t.Errorf("repl output %q", stdout)
+	}
+}
+
+func TestCliFormat(t *testing.T) {
+	_, stdout, _ := runCli("var   a=1\n", "fmt")
+	if stdout != "var a = 1\n" {
+		t.Errorf("fmt output %q", stdout)
+	}
+	_, stdout, _ = runCli("var   a=1\nvar b = 2\n", "fmt", "-d", "-")
+	want := "--- <stdin>.orig\n+++ <stdin>\n@@ -1,2 +1,2 @@\n-var   a=1\n+var a = 1\n var b = 2\n"
+	if stdout != want {
+		t.Errorf("fmt -d output %q", stdout)
+	}
+	fileName := filepath.Join(t.TempDir(), "test.dl")
+	os.WriteFile(fileName, []byte("var   a=1"), 0644)
+	if code, _, stderr := runCli("", "fmt", "-w", fileName); code != exitOK {
+		t.Fatalf("fmt -w exit code %d (%s)", code, stderr)
+	}
+	if content, _ := os.ReadFile(fileName); string(content) != "var a = 1\n" {
+		t.Errorf("fmt -w wrote %q", content)
+	}
+}
@@ -2,18 +2,70 @@ package parser
 
 import (
 	"fmt"
+	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"strings"
+	"unicode/utf8"
 )
 
 const (
 	ERR_UnexpectedToken      = "Unexpected token %v"
 	ERR_UnexpectedEndOfInput = "Unexpected end of input"
+	ERR_UnterminatedString   = "Unterminated string literal"
+	ERR_UnterminatedTemplate = "Unterminated template literal"
+	ERR_InvalidEscape        = "Octal escape sequences are not allowed"
+	ERR_InvalidHexEscape     = "Invalid hexadecimal escape sequence"
+	ERR_InvalidUnicodeEscape = "Invalid Unicode escape sequence"
+	ERR_InvalidNumber        = "Invalid or unexpected token in numeric literal"
+	ERR_NumericSeparator     = "Numeric separators are only allowed between digits"
+	ERR_LeadingZero          = "Decimal literals with leading zeros are not allowed"
+	ERR_InvalidBigInt        = "BigInt literals must be integers"
+	ERR_InvalidCharacter     = "Invalid or unexpected token"
+	ERR_InvalidAssignment    = "Invalid left-hand side in assignment"
+	ERR_InvalidForInOf       = "Invalid left-hand side in for-%s loop"
+	ERR_ForInOfBindings      = "for-%s loop accepts at most %d variables"
+	ERR_MissingInitializer   = "Missing initializer in const declaration"
+	ERR_DuplicateDefault     = "Already saw a default in switch"
+	ERR_IllegalReturn        = "Illegal return statement"
+	ERR_IllegalBreak         = "Illegal break statement"
+	ERR_IllegalContinue      = "Illegal continue statement"
+	ERR_UndefinedLabel       = "Undefined label '%s'"
+	ERR_DuplicateLabel       = "Label '%s' has already been declared"
 )
 
+// errorCodes maps each message format to a stable code for tools.
+var errorCodes = map[string]string{
+	ERR_UnexpectedToken:      "UnexpectedToken",
+	ERR_UnexpectedEndOfInput: "UnexpectedEndOfInput",
+	ERR_UnterminatedString:   "UnterminatedString",
+	ERR_UnterminatedTemplate: "UnterminatedTemplate",
+	ERR_InvalidEscape:        "InvalidEscape",
+	ERR_InvalidHexEscape:     "InvalidHexEscape",
+	ERR_InvalidUnicodeEscape: "InvalidUnicodeEscape",
+	ERR_InvalidNumber:        "InvalidNumber",
+	ERR_NumericSeparator:     "NumericSeparator",
+	ERR_LeadingZero:          "LeadingZero",
+	ERR_InvalidBigInt:        "InvalidBigInt",
+	ERR_InvalidCharacter:     "InvalidCharacter",
+	ERR_InvalidAssignment:    "InvalidAssignment",
+	ERR_InvalidForInOf:       "InvalidForInOf",
+	ERR_ForInOfBindings:      "ForInOfBindings",
+	ERR_MissingInitializer:   "MissingInitializer",
+	ERR_DuplicateDefault:     "DuplicateDefault",
+	ERR_IllegalReturn:        "IllegalReturn",
+	ERR_IllegalBreak:         "IllegalBreak",
+	ERR_IllegalContinue:      "IllegalContinue",
+	ERR_UndefinedLabel:       "UndefinedLabel",
+	ERR_DuplicateLabel:       "DuplicateLabel",
+}
+
+// Error is a syntax error covering the source from Position to End.
 type Error struct {
+	Code     string
 	Message  string
 	Position *file.Position
+	End      *file.Position
 }
 
 func (error *Error) Error() string {
@@ -40,22 +92,23 @@ func (errorList *ErrorList) Errors() error {
 	return nil
 }
 
+// Error lists every error, one per line.
 func (errorList *ErrorList) Error() string {
-	length := errorList.Length()
-	switch length {
-	case 0:
+	if errorList.Length() == 0 {
 		return "no errors"
-	case 1:
-		return errorList.FirstError().Error()
 	}
-	return fmt.Sprintf("%s (and %d more errors)", errorList.FirstError().Error(), length)
+	messages := make([]string, errorList.Length())
+	for i, error := range *errorList {
+		messages[i] = error.Error()
+	}
+	return strings.Join(messages, "\n")
 }
 
 func (errorList *ErrorList) AddError(error *Error) {
 	*errorList = append(*errorList, error)
 }
 func (errorList *ErrorList) Add(message string, position *file.Position) {
-	errorList.AddError(&Error{Message: message, Position: position})
+	errorList.AddError(&Error{Message: message, Position: position, End: position})
 }
 
 func (errorList ErrorList) Length() int {
@@ -70,27 +123,72 @@ func (errorList ErrorList) FirstError() *Error {
 }
 
 func (errorList ErrorList) LastError() *Error {
-	return errorList[errorList.Length()-1]
+	if errorList.Length() > 0 {
+		return errorList[errorList.Length()-1]
+	}
+	return nil
 }
 
-func (parser *Parser) errorUnexpected(index file.Index, tkn token.Token) error {
-	return parser.error(index, ERR_UnexpectedToken, tkn)
+// errorUnexpectedToken reports a token the grammar cannot continue with. The
+// parser then recovers: further errors are dropped until it synchronizes.
+func (parser *Parser) errorUnexpectedToken(tkn token.Token) *Error {
+	if tkn == token.EOF {
+		return parser.syntaxError(parser.index, parser.index, ERR_UnexpectedEndOfInput)
+	}
+	return parser.syntaxError(parser.index, parser.tokenEndOf(parser.index), ERR_UnexpectedToken, tkn.String())
 }
 
-func (parser *Parser) errorUnexpectedToken(tkn token.Token) error {
-	message := ERR_UnexpectedToken
-	messageValue := tkn.String()
-	switch tkn {
-	case token.EOF:
-		message = ERR_UnexpectedEndOfInput
-		break
-	}
-	return parser.error(parser.index, message, messageValue)
+func (parser *Parser) syntaxError(start, end file.Index, message string, messageValues ...any) *Error {
+	error := parser.errorRange(start, end, message, messageValues...)
+	parser.recovering = true
+	return error
 }
 
+// error reports an error at index, covering the current token when index is
+// its start and a single character otherwise. Unlike the other errors it is
+// kept during recovery, the lexer reports through it.
 func (parser *Parser) error(index file.Index, message string, messageValues ...any) *Error {
-	position := parser.Position(index)
-	message = fmt.Sprintf(message, messageValues...)
-	parser.errors.Add(message, position)
-	return parser.errors.LastError()
+	return parser.addError(index, parser.tokenEndOf(index), message, messageValues...)
+}
+
+func (parser *Parser) errorNode(node ast.Node, message string, messageValues ...any) *Error {
+	return parser.errorRange(node.StartIndex(), node.EndIndex(), message, messageValues...)
+}
+
+func (parser *Parser) errorRange(start, end file.Index, message string, messageValues ...any) *Error {
+	if parser.recovering {
+		return nil
+	}
+	return parser.addError(start, end, message, messageValues...)
+}
+
+func (parser *Parser) addError(start, end file.Index, message string, messageValues ...any) *Error {
+	position := parser.Position(start)
+	if last := parser.errors.LastError(); last != nil && *last.Position == *position {
+		// A second error at the same place is a consequence of the first.
+		return last
+	}
+	code, exists := errorCodes[message]
+	if !exists {
+		code = "SyntaxError"
+	}
+	error := &Error{
+		Code:     code,
+		Message:  fmt.Sprintf(message, messageValues...),
+		Position: position,
+		End:      parser.Position(end),
+	}
+	parser.errors.AddError(error)
+	return error
+}
+
+func (parser *Parser) tokenEndOf(index file.Index) file.Index {
+	if index == parser.index && parser.token != token.EOF && parser.literal != "" {
+		return index + file.Index(len(parser.literal))
+	}
+	if offset := int(index) - parser.baseOffset; offset < parser.length {
+		_, width := utf8.DecodeRuneInString(parser.content[offset:])
+		return index + file.Index(width)
+	}
+	return index
 }
@@ -4,7 +4,9 @@ import (
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 func (parser *Parser) parseBindingList() (bindingList []*ast.Binding) {
@@ -35,13 +37,12 @@ func (parser *Parser) parseBindingTarget() ast.BindingTarget {
 	case token.IDENTIFIER:
 		return parser.parseIdentifier()
 	default:
-		index := parser.expect(token.IDENTIFIER)
-		badExpression := &ast.BadExpression{
+		index := parser.index
+		parser.errorUnexpectedToken(parser.token)
+		return &ast.BadExpression{
 			Start: index,
 			End:   index,
 		}
-		parser.nextStatement()
-		return badExpression
 	}
 }
 
@@ -85,9 +86,12 @@ func (parser *Parser) parseFunBlock(openScope bool) (statement *ast.BlockStateme
 		parser.openScope()
 		defer parser.closeScope()
 	}
-	parser.scope.inFunction = true
+	// Labels, loops and switches outside the function are out of reach of its
+	// break and continue statements.
+	saved := *parser.scope
+	parser.scope.labels, parser.scope.inIteration, parser.scope.inSwitch, parser.scope.inFunction = nil, false, false, true
 	statement, variableDeclarations = parser.parseBlockStatement(), parser.scope.declarationList
-	parser.scope.inFunction = false
+	parser.scope.labels, parser.scope.inIteration, parser.scope.inSwitch, parser.scope.inFunction = saved.labels, saved.inIteration, saved.inSwitch, saved.inFunction
 	return
 }
 
@@ -134,17 +138,26 @@ func (parser *Parser) parseAssignExpression() ast.Expression {
 		operator = token.DIVIDE
 	case token.REMAINDER_ASSIGN:
 		operator = token.REMAINDER
+	case token.EXPONENT_ASSIGN:
+		operator = token.EXPONENT
 	case token.AND_ARITHMETIC_ASSIGN:
 		operator = token.AND_ARITHMETIC
 	case token.OR_ARITHMETIC_ASSIGN:
 		operator = token.OR_ARITHMETIC
+	case token.XOR_ARITHMETIC_ASSIGN:
+		operator = token.XOR_ARITHMETIC
+	case token.SHIFT_LEFT_ASSIGN:
+		operator = token.SHIFT_LEFT
+	case token.SHIFT_RIGHT_ASSIGN:
+		operator = token.SHIFT_RIGHT
+	case token.UNSIGNED_SHIFT_RIGHT_ASSIGN:
+		operator = token.UNSIGNED_SHIFT_RIGHT
 	case token.ARROW:
 		parser.restoreParseState(parseState)
 		left = parser.parseArrowFunctionLiteral()
 	}
 
 	if operator != 0 {
-		index := parser.index
 		err := true
 
 		switch left.(type) {
@@ -165,9 +178,10 @@ func (parser *Parser) parseAssignExpression() ast.Expression {
 			break
 		}
 		if err {
-			parser.error(left.StartIndex(), "Invalid left-hand side in assignment")
-			parser.nextStatement()
-			return &ast.BadExpression{Start: index, End: parser.index}
+			parser.errorNode(left, ERR_InvalidAssignment)
+			parser.expect(parser.token)
+			parser.parseAssignExpression()
+			return &ast.BadExpression{Start: left.StartIndex(), End: parser.tokenEnd}
 		}
 		parser.expect(parser.token)
 		return &ast.AssignExpression{
@@ -183,6 +197,16 @@ func (parser *Parser) parseAssignExpression() ast.Expression {
 func (parser *Parser) parseConditionalExpression() ast.Expression {
 	left := parser.parseLogicalOrExpression()
 
+	if parser.token == token.QUESTION {
+		parser.expect(token.QUESTION)
+		consequent := parser.parseAssignExpression()
+		parser.expect(token.COLON)
+		return &ast.ConditionalExpression{
+			Test:       left,
+			Consequent: consequent,
+			Alternate:  parser.parseAssignExpression(),
+		}
+	}
 	return left
 }
 
@@ -223,19 +247,52 @@ func (parser *Parser) parseLogicalAndExpression() ast.Expression {
 func (parser *Parser) parseBitwiseOrExpression() ast.Expression {
 	left := parser.parseBitwiseExclusiveOrExpression()
 
-	return left
+	for {
+		switch parser.token {
+		case token.OR_ARITHMETIC:
+			left = &ast.BinaryExpression{
+				Operator: parser.expectToken(parser.token),
+				Left:     left,
+				Right:    parser.parseBitwiseExclusiveOrExpression(),
+			}
+		default:
+			return left
+		}
+	}
 }
 
 func (parser *Parser) parseBitwiseExclusiveOrExpression() ast.Expression {
 	left := parser.parseBitwiseAndExpression()
 
-	return left
+	for {
+		switch parser.token {
+		case token.XOR_ARITHMETIC:
+			left = &ast.BinaryExpression{
+				Operator: parser.expectToken(parser.token),
+				Left:     left,
+				Right:    parser.parseBitwiseAndExpression(),
+			}
+		default:
+			return left
+		}
+	}
 }
 
 func (parser *Parser) parseBitwiseAndExpression() ast.Expression {
 	left := parser.parseEqualityExpression()
 
-	return left
+	for {
+		switch parser.token {
+		case token.AND_ARITHMETIC:
+			left = &ast.BinaryExpression{
+				Operator: parser.expectToken(parser.token),
+				Left:     left,
+				Right:    parser.parseEqualityExpression(),
+			}
+		default:
+			return left
+		}
+	}
 }
 
 func (parser *Parser) parseEqualityExpression() ast.Expression {
@@ -275,7 +332,18 @@ func (parser *Parser) parseRelationalExpression() ast.Expression {
 func (parser *Parser) parseShiftExpression() ast.Expression {
 	left := parser.parseAdditiveExpression()
 
-	return left
+	for {
+		switch parser.token {
+		case token.SHIFT_LEFT, token.SHIFT_RIGHT, token.UNSIGNED_SHIFT_RIGHT:
+			left = &ast.BinaryExpression{
+				Operator: parser.expectToken(parser.token),
+				Left:     left,
+				Right:    parser.parseAdditiveExpression(),
+			}
+		default:
+			return left
+		}
+	}
 }
 
 func (parser *Parser) parseAdditiveExpression() ast.Expression {
@@ -296,7 +364,7 @@ func (parser *Parser) parseAdditiveExpression() ast.Expression {
 }
 
 func (parser *Parser) parseMultiplicativeExpression() ast.Expression {
-	left := parser.parseExponentiationExpression()
+	left := parser.parseUnaryExpression()
 
 	for {
 		switch parser.token {
@@ -304,7 +372,7 @@ func (parser *Parser) parseMultiplicativeExpression() ast.Expression {
 			left = &ast.BinaryExpression{
 				Operator: parser.expectToken(parser.token),
 				Left:     left,
-				Right:    parser.parseExponentiationExpression(),
+				Right:    parser.parseUnaryExpression(),
 			}
 		default:
 			return left
@@ -312,17 +380,13 @@ func (parser *Parser) parseMultiplicativeExpression() ast.Expression {
 	}
 }
 
-func (parser *Parser) parseExponentiationExpression() ast.Expression {
-	left := parser.parseUnaryExpression()
-
-	return left
-}
-
+// parseUnaryExpression parses the unary operators, which bind weaker than
+// exponentiation, so -2 ** 2 is -(2 ** 2).
 func (parser *Parser) parseUnaryExpression() ast.Expression {
 
 	tkn := parser.token
 	switch tkn {
-	case token.NOT, token.ADDITION, token.SUBTRACT:
+	case token.NOT, token.BITWISE_NOT, token.ADDITION, token.SUBTRACT:
 		unaryExpression := &ast.UnaryExpression{
 			Index:    parser.expect(tkn),
 			Operator: tkn,
@@ -331,8 +395,21 @@ func (parser *Parser) parseUnaryExpression() ast.Expression {
 		return unaryExpression
 	}
 
+	left := parser.parseExponentiationExpression()
+
+	return left
+}
+
+func (parser *Parser) parseExponentiationExpression() ast.Expression {
 	left := parser.parseUpdateExpression()
 
+	if parser.token == token.EXPONENT {
+		return &ast.BinaryExpression{
+			Operator: parser.expectToken(parser.token),
+			Left:     left,
+			Right:    parser.parseUnaryExpression(),
+		}
+	}
 	return left
 }
 
@@ -362,12 +439,18 @@ func (parser *Parser) parseUpdateExpression() ast.Expression {
 
 	if isUpdate {
 		parser.next()
+		if !isPostfix {
+			operand = parser.parseUpdateExpression()
+		}
 		switch operand.(type) {
 		case *ast.Identifier, *ast.DotExpression, *ast.BracketExpression:
 		default:
-			parser.error(index, "Invalid left-hand side in assignment")
-			parser.nextStatement()
-			return &ast.BadExpression{Start: index, End: parser.index}
+			parser.errorNode(operand, ERR_InvalidAssignment)
+			start := index
+			if isPostfix {
+				start = operand.StartIndex()
+			}
+			return &ast.BadExpression{Start: start, End: parser.tokenEnd}
 		}
 		return &ast.UnaryExpression{
 			Index:    index,
@@ -406,6 +489,13 @@ func (parser *Parser) parseLeftHandSideExpressionAllowCall(stopTokens []token.To
 		case token.LEFT_PARENTHESIS:
 			left = parser.parseCallExpression(left)
 			continue
+		case token.BACKTICK:
+			// A template on the next line starts a new statement rather than tagging left.
+			if strings.ContainsAny(parser.slice(left.EndIndex(), parser.index), "\n\r") {
+				break
+			}
+			left = parser.parseTemplateLiteral(left)
+			continue
 		}
 		break
 	}
@@ -432,6 +522,8 @@ func (parser *Parser) parsePrimaryExpression() ast.Expression {
 		return parser.parseNumberLiteral()
 	case token.STRING:
 		return parser.parseStringLiteral()
+	case token.BACKTICK:
+		return parser.parseTemplateLiteral(nil)
 	case token.BOOLEAN:
 		return parser.parseBooleanLiteral()
 	case token.NULL:
@@ -449,10 +541,17 @@ func (parser *Parser) parsePrimaryExpression() ast.Expression {
 	}
 
 	parser.errorUnexpectedToken(parser.token)
-	parser.nextStatement()
+	switch parser.token {
+	case token.RIGHT_BRACE, token.RIGHT_PARENTHESIS, token.RIGHT_BRACKET, token.EOF:
+		// Leave closing brackets to the construct they end.
+	default:
+		if !isStatementStart(parser.token) {
+			parser.next()
+		}
+	}
 	return &ast.BadExpression{
 		Start: index,
-		End:   parser.index,
+		End:   parser.tokenEnd,
 	}
 }
 
@@ -474,11 +573,31 @@ func (parser *Parser) parseNumberLiteralValue(literal string) any {
 		value = v
 		return true
 	}
+	literal = strings.ReplaceAll(literal, "_", "")
+	if strings.HasSuffix(literal, "n") {
+		if bigValue, ok := new(big.Int).SetString(strings.TrimSuffix(literal, "n"), 0); ok {
+			return bigValue
+		}
+		return value
+	}
+	if len(literal) > 1 && literal[0] == '0' && isNumeric(rune(literal[1])) {
+		// Leading zeros are reported by the lexer, read the digits as decimal anyway.
+		literal = strings.TrimLeft(literal, "0")
+	}
 	intValue, err := strconv.ParseInt(literal, 0, 64)
 	if updateValue(intValue, err) {
 		return value
 	}
+	// Integers beyond int64 lose precision as floats.
+	if intValue, ok := new(big.Int).SetString(literal, 0); ok {
+		floatValue, _ := new(big.Float).SetInt(intValue).Float64()
+		return floatValue
+	}
 	floatValue, err := strconv.ParseFloat(literal, 64)
+	if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+		// Exponents out of range round to zero or infinity.
+		err = nil
+	}
 	if updateValue(floatValue, err) {
 		return value
 	}
@@ -494,6 +613,36 @@ func (parser *Parser) parseStringLiteral() ast.Expression {
 	}
 }
 
+// parseTemplateLiteral scans the template characters straight from the source,
+// the substitutions in between are parsed from the regular token stream.
+func (parser *Parser) parseTemplateLiteral(tag ast.Expression) ast.Expression {
+	templateLiteral := &ast.TemplateLiteral{
+		Tag:       tag,
+		OpenQuote: parser.index,
+	}
+	for {
+		element, finished := parser.scanTemplateElement()
+		templateLiteral.Elements = append(templateLiteral.Elements, element)
+		if finished {
+			break
+		}
+		parser.next()
+		templateLiteral.Expressions = append(templateLiteral.Expressions, parser.parseExpression())
+		if parser.token != token.RIGHT_BRACE {
+			parser.errorUnexpectedToken(parser.token)
+			templateLiteral.CloseQuote = parser.index
+			return templateLiteral
+		}
+	}
+	templateLiteral.CloseQuote = parser.IndexOf(parser.chrOffset)
+	if parser.chr == -1 {
+		parser.error(templateLiteral.OpenQuote, ERR_UnterminatedTemplate)
+	}
+	parser.readChr()
+	parser.next()
+	return templateLiteral
+}
+
 func (parser *Parser) parseBooleanLiteral() ast.Expression {
 	defer parser.expect(token.BOOLEAN)
 	return &ast.BooleanLiteral{
@@ -523,6 +672,9 @@ func (parser *Parser) parseArrayLiteral() ast.Expression {
 		if parser.token != token.RIGHT_BRACKET {
 			parser.expect(token.COMMA)
 		}
+		if parser.recovering {
+			break
+		}
 	}
 	arrayLiteral.Values = values
 	arrayLiteral.RightBracket = parser.expect(token.RIGHT_BRACKET)
@@ -542,6 +694,9 @@ func (parser *Parser) parseObjectLiteral() ast.Expression {
 		if parser.token != token.RIGHT_BRACE {
 			parser.expect(token.COMMA)
 		}
+		if parser.recovering {
+			break
+		}
 	}
 	objectLiteral.Properties = properties
 	objectLiteral.RightBrace = parser.expect(token.RIGHT_BRACE)
@@ -633,13 +788,27 @@ func (parser *Parser) parseCallExpression(left ast.Expression) *ast.CallExpressi
 
 func (parser *Parser) parseArguments() (leftParenthesis file.Index, arguments []ast.Expression, rightParenthesis file.Index) {
 	leftParenthesis = parser.expect(token.LEFT_PARENTHESIS)
-	for parser.token != token.RIGHT_PARENTHESIS {
+	for parser.token != token.RIGHT_PARENTHESIS && parser.token != token.EOF {
 		arguments = append(arguments, parser.parseExpression())
+		if parser.token != token.COMMA && parser.token != token.RIGHT_PARENTHESIS {
+			parser.errorUnexpectedToken(parser.token)
+			parser.synchronize(func() bool {
+				return parser.token == token.COMMA || isStatementStart(parser.token)
+			})
+		}
 		if parser.token != token.COMMA {
 			break
 		}
+		parser.recovering = false
 		parser.expect(token.COMMA)
 	}
+	if parser.token != token.RIGHT_PARENTHESIS {
+		// Leave the token to the enclosing statement, the call ends after its last argument.
+		if parser.token == token.EOF {
+			parser.errorUnexpectedToken(parser.token)
+		}
+		return leftParenthesis, arguments, parser.tokenEnd - 1
+	}
 	rightParenthesis = parser.expect(token.RIGHT_PARENTHESIS)
 	return
 }
@@ -1,9 +1,14 @@
 package parser
 
 import (
+	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 func (parser *Parser) scan() (tkn token.Token, literal string, value string, index file.Index) {
@@ -29,13 +34,10 @@ func (parser *Parser) scan() (tkn token.Token, literal string, value string, ind
 			}
 			break
 		case isStringSymbol(chr):
-			parser.readChr()
-			value = parser.scanString()
-			literal = string(chr) + value + string(parser.chr)
+			literal, value = parser.scanString()
 			tkn = token.STRING
-			parser.readChr()
 			break
-		case isNumeric(chr):
+		case isNumeric(chr) || chr == '.' && isNumeric(parser.peekChr()):
 			literal = parser.scanNumericLiteral()
 			value = literal
 			tkn = token.NUMBER
@@ -57,7 +59,12 @@ func (parser *Parser) scan() (tkn token.Token, literal string, value string, ind
 				value = tkn.String()
 				break
 			case '*':
-				tkn = parser.switchToken("=", token.MULTIPLY_ASSIGN, token.MULTIPLY)
+				if parser.chr == '*' {
+					parser.readChr()
+					tkn = parser.switchToken("=", token.EXPONENT_ASSIGN, token.EXPONENT)
+				} else {
+					tkn = parser.switchToken("=", token.MULTIPLY_ASSIGN, token.MULTIPLY)
+				}
 				literal = tkn.String()
 				value = tkn.String()
 				break
@@ -115,6 +122,9 @@ func (parser *Parser) scan() (tkn token.Token, literal string, value string, ind
 			case ':':
 				tkn, literal, value = token.COLON, string(chr), string(chr)
 				break
+			case '?':
+				tkn, literal, value = token.QUESTION, string(chr), string(chr)
+				break
 			case ';':
 				tkn, literal, value = token.SEMICOLON, string(chr), string(chr)
 				break
@@ -129,12 +139,27 @@ func (parser *Parser) scan() (tkn token.Token, literal string, value string, ind
 				value = tkn.String()
 				break
 			case '<':
-				tkn = parser.switchToken("=", token.LESS_OR_EQUAL, token.LESS)
+				if parser.chr == '<' {
+					parser.readChr()
+					tkn = parser.switchToken("=", token.SHIFT_LEFT_ASSIGN, token.SHIFT_LEFT)
+				} else {
+					tkn = parser.switchToken("=", token.LESS_OR_EQUAL, token.LESS)
+				}
 				literal = tkn.String()
 				value = tkn.String()
 				break
 			case '>':
-				tkn = parser.switchToken("=", token.GREATER_OR_EQUAL, token.GREATER)
+				if parser.chr == '>' {
+					parser.readChr()
+					if parser.chr == '>' {
+						parser.readChr()
+						tkn = parser.switchToken("=", token.UNSIGNED_SHIFT_RIGHT_ASSIGN, token.UNSIGNED_SHIFT_RIGHT)
+					} else {
+						tkn = parser.switchToken("=", token.SHIFT_RIGHT_ASSIGN, token.SHIFT_RIGHT)
+					}
+				} else {
+					tkn = parser.switchToken("=", token.GREATER_OR_EQUAL, token.GREATER)
+				}
 				literal = tkn.String()
 				value = tkn.String()
 				break
@@ -148,9 +173,20 @@ func (parser *Parser) scan() (tkn token.Token, literal string, value string, ind
 				literal = tkn.String()
 				value = tkn.String()
 				break
+			case '^':
+				tkn = parser.switchToken("=", token.XOR_ARITHMETIC_ASSIGN, token.XOR_ARITHMETIC)
+				literal = tkn.String()
+				value = tkn.String()
+				break
+			case '~':
+				tkn, literal, value = token.BITWISE_NOT, string(chr), string(chr)
+				break
+			case '`':
+				tkn, literal, value = token.BACKTICK, string(chr), string(chr)
+				break
 			default:
 				tkn = token.ILLEGAL
-				parser.errorUnexpected(index, tkn)
+				parser.error(index, ERR_InvalidCharacter)
 				break
 			}
 		}
@@ -164,11 +200,22 @@ func (parser *Parser) skipWhiteSpaceChr() {
 	}
 }
 
+func (parser *Parser) peekChr() rune {
+	if parser.offset < parser.length {
+		chr, _ := utf8.DecodeRuneInString(parser.content[parser.offset:])
+		return chr
+	}
+	return -1
+}
+
+// readChr decodes the next UTF-8 encoded character, chrOffset stays a byte
+// offset so that the source can be sliced by it.
 func (parser *Parser) readChr() rune {
 	if parser.offset < parser.length {
+		chr, width := utf8.DecodeRuneInString(parser.content[parser.offset:])
 		parser.chrOffset = parser.offset
-		parser.chr = rune(parser.content[parser.offset])
-		parser.offset += 1
+		parser.chr = chr
+		parser.offset += width
 		return parser.chr
 	}
 	parser.chrOffset = parser.length
@@ -188,12 +235,254 @@ func (parser *Parser) scanIdentifier() string {
 	return parser.scanByFilter(isIdentifierPart)
 }
 
+// scanNumericLiteral scans a decimal literal with optional fraction and exponent,
+// or a hexadecimal, octal or binary integer introduced by 0x, 0o or 0b. Integers
+// suffixed with n are BigInt literals.
 func (parser *Parser) scanNumericLiteral() string {
-	return parser.scanByFilter(isNumericPart)
+	chrOffset := parser.chrOffset
+	if parser.chr == '0' {
+		var isDigit func(rune) bool
+		switch parser.peekChr() {
+		case 'x', 'X':
+			isDigit = isHexDigit
+		case 'o', 'O':
+			isDigit = isOctalDigit
+		case 'b', 'B':
+			isDigit = isBinaryDigit
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '_':
+			parser.error(parser.IndexOf(chrOffset), ERR_LeadingZero)
+		}
+		if isDigit != nil {
+			parser.readChr()
+			parser.readChr()
+			if !parser.scanDigits(isDigit) {
+				parser.error(parser.IndexOf(parser.chrOffset), ERR_InvalidNumber)
+			}
+			if parser.chr == 'n' {
+				parser.readChr()
+			}
+			parser.checkNumericEnd()
+			return parser.content[chrOffset:parser.chrOffset]
+		}
+	}
+	parser.scanDigits(isNumeric)
+	isInteger := true
+	// A dot followed by an identifier is a member access, 5.x, otherwise it
+	// belongs to the literal, so 5. is a float.
+	if parser.chr == '.' && !isIdentifierStart(parser.peekChr()) {
+		isInteger = false
+		parser.readChr()
+		if isNumeric(parser.chr) {
+			parser.scanDigits(isNumeric)
+		}
+	}
+	if parser.chr == 'e' || parser.chr == 'E' {
+		isInteger = false
+		parser.readChr()
+		if parser.chr == '+' || parser.chr == '-' {
+			parser.readChr()
+		}
+		if !parser.scanDigits(isNumeric) {
+			parser.error(parser.IndexOf(parser.chrOffset), ERR_InvalidNumber)
+		}
+	}
+	if parser.chr == 'n' {
+		if !isInteger {
+			parser.error(parser.IndexOf(parser.chrOffset), ERR_InvalidBigInt)
+		}
+		parser.readChr()
+	}
+	parser.checkNumericEnd()
+	return parser.content[chrOffset:parser.chrOffset]
+}
+
+// scanDigits scans a run of digits in which a single underscore may separate two
+// digits, it reports whether any digit was found.
+func (parser *Parser) scanDigits(isDigit func(rune) bool) bool {
+	found := false
+	for {
+		switch {
+		case isDigit(parser.chr):
+			found = true
+			parser.readChr()
+		case parser.chr == '_':
+			index := parser.IndexOf(parser.chrOffset)
+			parser.readChr()
+			if !found || !isDigit(parser.chr) {
+				parser.error(index, ERR_NumericSeparator)
+			}
+		default:
+			return found
+		}
+	}
+}
+
+// checkNumericEnd rejects a literal that runs straight into digits, letters or a
+// second fraction, such as 3in, 0b12 or 1.2.3, the rest of the run is consumed.
+func (parser *Parser) checkNumericEnd() {
+	if !isIdentifierPart(parser.chr) && !(parser.chr == '.' && isNumeric(parser.peekChr())) {
+		return
+	}
+	parser.error(parser.IndexOf(parser.chrOffset), ERR_InvalidNumber)
+	for isIdentifierPart(parser.chr) || parser.chr == '.' && isNumeric(parser.peekChr()) {
+		parser.readChr()
+	}
+}
+
+// scanString scans a string literal closed by the same kind of quote that opens
+// it and returns the raw source together with the decoded value.
+func (parser *Parser) scanString() (literal string, value string) {
+	quote := parser.chr
+	chrOffset := parser.chrOffset
+	parser.readChr()
+	var builder strings.Builder
+	for parser.chr != quote {
+		switch {
+		case isLineTerminator(parser.chr):
+			parser.error(parser.IndexOf(chrOffset), ERR_UnterminatedString)
+			return parser.content[chrOffset:parser.chrOffset], builder.String()
+		case parser.chr == '\\':
+			parser.scanEscape(&builder)
+		default:
+			builder.WriteRune(parser.chr)
+			parser.readChr()
+		}
+	}
+	parser.readChr()
+	return parser.content[chrOffset:parser.chrOffset], builder.String()
 }
 
-func (parser *Parser) scanString() string {
-	return parser.scanByFilter(isNotStringSymbol)
+// scanTemplateElement scans the characters of a template literal up to the
+// closing backtick, the end of input or the next "${", the latter leaves
+// finished false with the "${" consumed.
+func (parser *Parser) scanTemplateElement() (element *ast.TemplateElement, finished bool) {
+	chrOffset := parser.chrOffset
+	element = &ast.TemplateElement{Index: parser.IndexOf(chrOffset)}
+	var builder strings.Builder
+	for {
+		switch parser.chr {
+		case '`', -1:
+			element.Literal, element.Parsed = parser.content[chrOffset:parser.chrOffset], builder.String()
+			return element, true
+		case '$':
+			if parser.peekChr() == '{' {
+				element.Literal, element.Parsed = parser.content[chrOffset:parser.chrOffset], builder.String()
+				parser.readChr()
+				parser.readChr()
+				return element, false
+			}
+			builder.WriteRune(parser.chr)
+			parser.readChr()
+		case '\\':
+			parser.scanEscape(&builder)
+		case '\r':
+			builder.WriteByte('\n')
+			if parser.readChr() == '\n' {
+				parser.readChr()
+			}
+		default:
+			builder.WriteRune(parser.chr)
+			parser.readChr()
+		}
+	}
+}
+
+// scanEscape decodes the escape sequence starting at the current backslash.
+func (parser *Parser) scanEscape(builder *strings.Builder) {
+	index := parser.IndexOf(parser.chrOffset)
+	chr := parser.readChr()
+	switch chr {
+	case 'n':
+		builder.WriteByte('\n')
+	case 't':
+		builder.WriteByte('\t')
+	case 'r':
+		builder.WriteByte('\r')
+	case 'b':
+		builder.WriteByte('\b')
+	case 'f':
+		builder.WriteByte('\f')
+	case 'v':
+		builder.WriteByte('\v')
+	case '0':
+		if isNumeric(parser.peekChr()) {
+			parser.error(index, ERR_InvalidEscape)
+		}
+		builder.WriteByte(0)
+	case 'x':
+		parser.readChr()
+		value, ok := parser.scanHexDigits(2)
+		if !ok {
+			parser.error(index, ERR_InvalidHexEscape)
+			return
+		}
+		builder.WriteRune(rune(value))
+		return
+	case 'u':
+		parser.readChr()
+		value, ok := parser.scanUnicodeEscape()
+		if !ok {
+			parser.error(index, ERR_InvalidUnicodeEscape)
+			return
+		}
+		if utf16.IsSurrogate(value) && parser.chr == '\\' && parser.peekChr() == 'u' {
+			state := parser.markParseState()
+			parser.readChr()
+			parser.readChr()
+			if low, ok := parser.scanUnicodeEscape(); ok && utf16.DecodeRune(value, low) != unicode.ReplacementChar {
+				value = utf16.DecodeRune(value, low)
+			} else {
+				parser.restoreParseState(state)
+			}
+		}
+		builder.WriteRune(value)
+		return
+	case '\r':
+		if parser.peekChr() == '\n' {
+			parser.readChr()
+		}
+	case '\n', '\u2028', '\u2029':
+	case -1:
+		return
+	default:
+		builder.WriteRune(chr)
+	}
+	parser.readChr()
+}
+
+// scanUnicodeEscape scans the digits of a \uXXXX or \u{X...} escape.
+func (parser *Parser) scanUnicodeEscape() (rune, bool) {
+	if parser.chr != '{' {
+		value, ok := parser.scanHexDigits(4)
+		return rune(value), ok
+	}
+	parser.readChr()
+	chrOffset := parser.chrOffset
+	for isHexDigit(parser.chr) {
+		parser.readChr()
+	}
+	digits := parser.content[chrOffset:parser.chrOffset]
+	if parser.chr != '}' || len(digits) == 0 {
+		return 0, false
+	}
+	parser.readChr()
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || value > unicode.MaxRune {
+		return 0, false
+	}
+	return rune(value), true
+}
+
+func (parser *Parser) scanHexDigits(count int) (uint64, bool) {
+	chrOffset := parser.chrOffset
+	for i := 0; i < count; i++ {
+		if !isHexDigit(parser.chr) {
+			return 0, false
+		}
+		parser.readChr()
+	}
+	value, err := strconv.ParseUint(parser.content[chrOffset:parser.chrOffset], 16, 32)
+	return value, err == nil
 }
 
 func (parser *Parser) scanComment(tkn token.Token) string {
@@ -229,28 +518,38 @@ func (parser *Parser) switchToken(keysStr string, tkns ...token.Token) token.Tok
 }
 
 func isWhiteSpaceChr(chr rune) bool {
-	return chr == ' ' || chr == '\t' || chr == '\r' || chr == '\n' || chr == '\f'
+	switch chr {
+	case ' ', '\t', '\r', '\n', '\f', '\v', '\u00a0', '\ufeff', '\u2028', '\u2029':
+		return true
+	}
+	return chr >= utf8.RuneSelf && unicode.Is(unicode.Zs, chr)
 }
 
 func isIdentifierStart(chr rune) bool {
-	return chr == '$' || chr == '_' || (chr >= 'A' && chr <= 'Z') || (chr >= 'a' && chr <= 'z')
+	return chr == '$' || chr == '_' || (chr >= 'A' && chr <= 'Z') || (chr >= 'a' && chr <= 'z') ||
+		chr >= utf8.RuneSelf && unicode.In(chr, unicode.Letter, unicode.Nl)
 }
 func isIdentifierPart(chr rune) bool {
-	return isIdentifierStart(chr) || isNumeric(chr)
+	return isIdentifierStart(chr) || isNumeric(chr) ||
+		chr >= utf8.RuneSelf && (unicode.In(chr, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc) || chr == '\u200c' || chr == '\u200d')
 }
 
 func isNumeric(chr rune) bool {
 	return chr >= '0' && chr <= '9'
 }
-func isNumericPart(chr rune) bool {
-	return chr == '.' || isNumeric(chr)
+func isOctalDigit(chr rune) bool {
+	return chr >= '0' && chr <= '7'
+}
+func isBinaryDigit(chr rune) bool {
+	return chr == '0' || chr == '1'
 }
 
 func isStringSymbol(chr rune) bool {
 	return chr == '"' || chr == '\''
 }
-func isNotStringSymbol(chr rune) bool {
-	return !isStringSymbol(chr)
+
+func isHexDigit(chr rune) bool {
+	return isNumeric(chr) || (chr >= 'a' && chr <= 'f') || (chr >= 'A' && chr <= 'F')
 }
 
 func isLineTerminator(chr rune) bool {
@@ -2,8 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"math/big"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -11,7 +14,7 @@ func TestLexer(t *testing.T) {
 	content, _ := os.ReadFile("../example/example.dl")
 	parser := CreateParser(1, "", string(content), false, false)
 	for parser.token != token.EOF {
-		parser.next()
+		parser.token, parser.literal, parser.value, parser.index = parser.scan()
 		fmt.Printf(`
 		{
 			Token: %s, 
@@ -23,3 +26,133 @@ func TestLexer(t *testing.T) {
 		println(err.Error())
 	}
 }
+
+func TestScanString(t *testing.T) {
+	tests := map[string]string{
+		`"it's"`:            "it's",
+		`'say "hi"'`:        `say "hi"`,
+		`"a\tb\nc\\"`:       "a\tb\nc\\",
+		`"\x41B\u{43}"`:     "ABC",
+		`"\u{1F600}😀"`:      "\U0001F600\U0001F600",
+		`"\uD83D\uDE00"`:    "\U0001F600",
+		"\"line \\\ncont\"": "line cont",
+	}
+	for source, expected := range tests {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if parser.token != token.STRING || parser.literal != source || parser.value != expected {
+			t.Errorf("%s: got %s %q %q, want %q", source, parser.token, parser.literal, parser.value, expected)
+		}
+		if parser.errors.Length() > 0 {
+			t.Errorf("%s: %v", source, parser.errors.Error())
+		}
+	}
+	for _, source := range []string{`"abc`, `'abc"`, "\"ab\nc\"", `"\x4"`, `"\u{110000}"`} {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if parser.errors.Length() == 0 {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+}
+
+func TestUnicodeSource(t *testing.T) {
+	parser := CreateParser(1, "", `var 东风 = "东风"; var café_ǅx = 1`, true, true)
+	var literals []string
+	for parser.next(); parser.token != token.EOF; parser.next() {
+		if parser.token == token.IDENTIFIER || parser.token == token.STRING {
+			literals = append(literals, parser.value)
+		}
+	}
+	if strings.Join(literals, ",") != "东风,东风,café_ǅx" || parser.errors.Length() > 0 {
+		t.Errorf("got %q, %v", literals, parser.errors.Error())
+	}
+
+	tests := []struct {
+		mode   file.ColumnMode
+		column int
+	}{
+		{file.ColumnCharacters, 9},
+		{file.ColumnUTF16, 10},
+	}
+	for _, test := range tests {
+		parser := CreateParser(1, "", `"😀东风" + §`, true, true)
+		parser.SetColumnMode(test.mode)
+		parser.Parse()
+		if parser.errors.Length() == 0 || parser.errors.FirstError().Position.Column != test.column {
+			t.Errorf("mode %d: got %v, want column %d", test.mode, parser.errors.Error(), test.column)
+		}
+	}
+}
+
+func TestScanNumericLiteral(t *testing.T) {
+	tests := map[string]any{
+		"42":                    int64(42),
+		"0xFF":                  int64(255),
+		"0o755":                 int64(493),
+		"0b1010":                int64(10),
+		"1_000_000":             int64(1000000),
+		"1.5":                   1.5,
+		".5":                    0.5,
+		"5.":                    5.0,
+		"1e-9":                  1e-9,
+		"2E+3":                  2000.0,
+		"1_0.2_5e1_0":           10.25e10,
+		"9223372036854775808":   9223372036854775808.0,
+		"0xFFFFFFFFFFFFFFFFFFF": 75557863725914323419135.0,
+	}
+	for source, expected := range tests {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if parser.token != token.NUMBER || parser.literal != source || parser.errors.Length() > 0 {
+			t.Errorf("%s: got %s %q, %v", source, parser.token, parser.literal, parser.errors.Error())
+		}
+		if value := parser.parseNumberLiteralValue(parser.value); value != expected {
+			t.Errorf("%s: got %v, want %v", source, value, expected)
+		}
+	}
+	for source, expected := range map[string]string{"123n": "123", "0x1_Fn": "31", "18446744073709551616n": "18446744073709551616"} {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if value, ok := parser.parseNumberLiteralValue(parser.value).(*big.Int); !ok || value.String() != expected || parser.errors.Length() > 0 {
+			t.Errorf("%s: got %v, want %s", source, value, expected)
+		}
+	}
+	// A dot before an identifier is left for a member access.
+	for source, expected := range map[string]string{"5.\n)": "5.", "5.x": "5", "1.5.x": "1.5"} {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if parser.token != token.NUMBER || parser.literal != expected {
+			t.Errorf("%q: got %s %q, want %q", source, parser.token, parser.literal, expected)
+		}
+	}
+	errors := map[string]int{
+		"1.2.3":  4,
+		"3in":    2,
+		"0b12":   4,
+		"0x":     3,
+		"1__0":   2,
+		"1_":     2,
+		"1e":     3,
+		"1_.5":   2,
+		"0755":   1,
+		"12_e3":  3,
+		"0o8":    3,
+		"1.5_":   4,
+		"0x1.5":  4,
+		"1e+_5":  4,
+		"0x_ff":  3,
+		"1.5n":   4,
+		"1e3n":   4,
+		"100abc": 4,
+	}
+	for source, column := range errors {
+		parser := CreateParser(1, "", source, true, true)
+		parser.next()
+		if parser.errors.Length() == 0 {
+			t.Errorf("%s: expected an error", source)
+		} else if position := parser.errors.FirstError().Position; position.Column != column {
+			t.Errorf("%s: got column %d, want %d", source, position.Column, column)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"strings"
 )
 
 type Parser struct {
@@ -22,21 +23,41 @@ type Parser struct {
 	value     string
 	index     file.Index
 
-	errors ErrorList
+	errors     ErrorList
+	recovering bool
 
 	scope *Scope
+
+	tokenEnd      file.Index
+	trivia        []*ast.Trivia
+	pendingTrivia []*ast.Trivia
+	triviaMap     ast.TriviaMap
 }
 
+// CreateParser creates a parser for content. When skipComment or skipWhiteSpace
+// is false the parser runs in concrete syntax mode: the kept comments and
+// whitespace are recorded as trivia on the program and attached to the
+// statements, cases and class members around them.
 func CreateParser(baseOffset int, fileName string, content string, skipComment bool, skipWhiteSpace bool) *Parser {
-	return &Parser{
+	parser := &Parser{
 		baseOffset:     baseOffset,
 		file:           file.CreateFile(baseOffset, fileName, content),
 		skipComment:    skipComment,
 		skipWhiteSpace: skipWhiteSpace,
 		content:        content,
 		length:         len(content),
-		chr:            ' ',
 	}
+	if parser.keepTrivia() {
+		parser.triviaMap = ast.TriviaMap{}
+	}
+	parser.readChr()
+	return parser
+}
+
+// SetColumnMode selects whether the columns of positions and errors count
+// characters or UTF-16 code units.
+func (parser *Parser) SetColumnMode(mode file.ColumnMode) {
+	parser.file.ColumnMode = mode
 }
 
 func (parser *Parser) Parse() (*ast.Program, error) {
@@ -52,21 +73,44 @@ func (parser *Parser) ScanNextToken() (token.Token, string, string, file.Index)
 
 func (parser *Parser) parseProgram() *ast.Program {
 	parser.next()
-	return &ast.Program{
+	program := &ast.Program{
 		Body:            parser.parseStatementList(),
 		DeclarationList: parser.scope.declarationList,
 		File:            parser.file,
 	}
+	if parser.keepTrivia() {
+		program.Trivia = parser.trivia
+		program.TriviaMap = parser.triviaMap
+		program.Dangling = parser.takeTrivia()
+	}
+	return program
 }
 
 func (parser *Parser) next() {
-	parser.token, parser.literal, parser.value, parser.index = parser.scan()
+	parser.tokenEnd = parser.IndexOf(parser.chrOffset)
+	parser.pendingTrivia = nil
+	for {
+		parser.token, parser.literal, parser.value, parser.index = parser.scan()
+		switch parser.token {
+		case token.COMMENT, token.MULTI_COMMENT, token.WHITE_SPACE:
+			parser.addTrivia()
+		default:
+			return
+		}
+	}
 }
 
+// expect consumes a token of kind tkn. A different token is reported and
+// skipped, unless it likely belongs to an enclosing construct: a closing brace
+// or a statement keyword on a new line.
 func (parser *Parser) expect(tkn token.Token) file.Index {
 	index := parser.index
 	if parser.token != tkn {
 		parser.errorUnexpectedToken(parser.token)
+		if parser.token == token.RIGHT_BRACE || parser.token == token.EOF ||
+			isStatementStart(parser.token) && parser.onNewLine() {
+			return index
+		}
 	}
 	parser.next()
 	return index
@@ -80,6 +124,37 @@ func (parser *Parser) expectToken(tkn token.Token) token.Token {
 	return tkn
 }
 
+// synchronize ends error recovery. It skips tokens, and any brackets they
+// open, until stop accepts a token or a closing bracket ends the enclosing
+// construct.
+func (parser *Parser) synchronize(stop func() bool) {
+	depth := 0
+	for parser.token != token.EOF {
+		switch parser.token {
+		case token.LEFT_BRACE, token.LEFT_PARENTHESIS, token.LEFT_BRACKET:
+			depth++
+		case token.RIGHT_BRACE, token.RIGHT_PARENTHESIS, token.RIGHT_BRACKET:
+			if depth == 0 {
+				parser.recovering = false
+				return
+			}
+			depth--
+		default:
+			if depth == 0 && stop() {
+				parser.recovering = false
+				return
+			}
+		}
+		parser.next()
+	}
+	parser.recovering = false
+}
+
+// onNewLine reports whether a line terminator precedes the current token.
+func (parser *Parser) onNewLine() bool {
+	return strings.ContainsAny(parser.slice(parser.tokenEnd, parser.index), lineTerminators)
+}
+
 func (parser *Parser) IndexOf(offset int) file.Index {
 	return file.Index(parser.baseOffset + offset)
 }
@@ -106,6 +181,11 @@ type ParseState struct {
 	value      string
 	index      file.Index
 	errorIndex int
+	recovering bool
+
+	tokenEnd      file.Index
+	triviaLength  int
+	pendingTrivia []*ast.Trivia
 }
 
 func (parser *Parser) markParseState() *ParseState {
@@ -118,6 +198,11 @@ func (parser *Parser) markParseState() *ParseState {
 		value:      parser.value,
 		index:      parser.index,
 		errorIndex: parser.errors.Length(),
+		recovering: parser.recovering,
+
+		tokenEnd:      parser.tokenEnd,
+		triviaLength:  len(parser.trivia),
+		pendingTrivia: parser.pendingTrivia,
 	}
 }
 
@@ -130,4 +215,8 @@ func (parser *Parser) restoreParseState(parseState *ParseState) {
 	parser.value = parseState.value
 	parser.index = parseState.index
 	parser.errors = parser.errors[:parseState.errorIndex]
+	parser.recovering = parseState.recovering
+	parser.tokenEnd = parseState.tokenEnd
+	parser.trivia = parser.trivia[:parseState.triviaLength]
+	parser.pendingTrivia = parseState.pendingTrivia
 }
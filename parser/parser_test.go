@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"fmt"
+	"github.com/istrangers/demolanguage/ast"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -15,3 +18,88 @@ func TestParser(t *testing.T) {
 	}
 	println(program)
 }
+
+func TestConcreteSyntax(t *testing.T) {
+	joinTrivia := func(triviaList []*ast.Trivia) string {
+		var builder strings.Builder
+		for _, trivia := range triviaList {
+			builder.WriteString(trivia.Literal)
+		}
+		return builder.String()
+	}
+	reproduce := func(program *ast.Program, content string) string {
+		var builder strings.Builder
+		for _, statement := range program.Body {
+			builder.WriteString(joinTrivia(program.TriviaMap.Leading(statement)))
+			builder.WriteString(content[statement.StartIndex()-1 : statement.EndIndex()-1])
+			builder.WriteString(joinTrivia(program.TriviaMap.Trailing(statement)))
+		}
+		builder.WriteString(joinTrivia(program.Dangling))
+		return builder.String()
+	}
+	for _, fileName := range []string{"../example/example.dl", "../example/vm_example.dl"} {
+		content, _ := os.ReadFile(fileName)
+		program, err := CreateParser(1, "", string(content), false, false).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", fileName, err)
+		}
+		if source := reproduce(program, string(content)); source != string(content) {
+			t.Errorf("%s: reproduced source differs", fileName)
+		}
+	}
+
+	content := "\n// doc\nvar a = 1 // one\nclass A {\n  /* field */\n  public b = 2\n}\nif a {\n  // empty\n}\r\n"
+	program, err := CreateParser(1, "", content, false, true).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source := joinTrivia(program.Trivia); source != "// doc// one/* field */// empty" {
+		t.Errorf("trivia %q", source)
+	}
+	classDeclaration := program.Body[1].(*ast.ClassDeclaration)
+	ifStatement := program.Body[2].(*ast.IfStatement)
+	for _, test := range []struct {
+		triviaList []*ast.Trivia
+		expected   string
+	}{
+		{program.TriviaMap.Leading(program.Body[0]), "// doc"},
+		{program.TriviaMap.Trailing(program.Body[0]), "// one"},
+		{program.TriviaMap.Leading(classDeclaration.Body[0]), "/* field */"},
+		{program.TriviaMap.Dangling(ifStatement.Consequent), "// empty"},
+	} {
+		if source := joinTrivia(test.triviaList); source != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, source)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	content := "var a = (1 + \nfoo(1, , 3)\nclass A {\n  public x = 1\n  foo bar\n}\nif a {\n  x = )\n}\nreturn 1\nvar d = 09\nfor ;; { fun f() { break } }\n"
+	parser := CreateParser(1, "", content, true, true)
+	_, err := parser.Parse()
+	errorList, ok := err.(*ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %v", err)
+	}
+	expected := []string{
+		"UnexpectedToken 2:8-2:9",
+		"UnexpectedToken 3:1-3:6",
+		"UnexpectedToken 5:3-5:6",
+		"UnexpectedToken 8:7-8:8",
+		"IllegalReturn 10:1-10:7",
+		"LeadingZero 11:9-11:10",
+		"IllegalBreak 12:20-12:25",
+	}
+	if errorList.Length() != len(expected) {
+		t.Fatalf("expected %d errors, got:\n%v", len(expected), err)
+	}
+	for i, error := range *errorList {
+		actual := fmt.Sprintf("%s %d:%d-%d:%d", error.Code, error.Position.Line, error.Position.Column, error.End.Line, error.End.Column)
+		if actual != expected[i] {
+			t.Errorf("error %d: expected %s, got %s", i, expected[i], actual)
+		}
+	}
+	if lines := strings.Count(err.Error(), "\n") + 1; lines != len(expected) {
+		t.Errorf("expected every error in the message, got %q", err.Error())
+	}
+}
@@ -16,6 +16,15 @@ func (scpoe *Scope) AddDeclaration(declaration *ast.VariableDeclaration) {
 	scpoe.declarationList = append(scpoe.declarationList, declaration)
 }
 
+func (scpoe *Scope) hasLabel(name string) bool {
+	for _, label := range scpoe.labels {
+		if label == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (parser *Parser) openScope() {
 	parser.scope = &Scope{
 		outer: parser.scope,
@@ -24,6 +33,7 @@ func (parser *Parser) openScope() {
 	outer := parser.scope.outer
 	if outer != nil {
 		scope.inSwitch, scope.inIteration, scope.inFunction = outer.inSwitch, outer.inIteration, outer.inFunction
+		scope.labels = outer.labels
 	}
 }
 
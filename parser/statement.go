@@ -4,6 +4,7 @@ import (
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"strings"
 )
 
 func (parser *Parser) parseStatementList() []ast.Statement {
@@ -15,23 +16,37 @@ func (parser *Parser) parseStatementList() []ast.Statement {
 func (parser *Parser) parseStatementListByCondition(endCondition func(token.Token) bool) []ast.Statement {
 	var statementList []ast.Statement
 	for endCondition(parser.token) {
-		statementList = append(statementList, parser.parseStatement())
+		leading := parser.takeTrivia()
+		index := parser.index
+		statement := parser.parseStatement()
+		parser.attachTrivia(statement, leading)
+		statementList = append(statementList, statement)
+		if parser.recovering {
+			parser.synchronizeStatement(index)
+		}
 	}
 	return statementList
 }
 
-func (parser *Parser) nextStatement() {
-	for {
-		switch parser.token {
-		case token.VAR, token.FUN, token.RETURN, token.IF,
-			token.FOR, token.SWITCH, token.BREAK, token.CONTINUE,
-			token.THROW, token.TRY, token.CATCH, token.FINALLY:
-			return
-		case token.EOF:
-			return
-		}
+// synchronizeStatement skips the rest of a statement that failed to parse
+// from index, up to a statement keyword or the start of the next line.
+func (parser *Parser) synchronizeStatement(index file.Index) {
+	if parser.index == index {
 		parser.next()
 	}
+	parser.synchronize(func() bool {
+		return isStatementStart(parser.token) || parser.onNewLine()
+	})
+}
+
+func isStatementStart(tkn token.Token) bool {
+	switch tkn {
+	case token.VAR, token.LET, token.CONST, token.FUN, token.RETURN, token.IF,
+		token.FOR, token.WHILE, token.DO, token.SWITCH, token.BREAK, token.CONTINUE,
+		token.THROW, token.TRY, token.CLASS:
+		return true
+	}
+	return false
 }
 
 func (parser *Parser) parseStatement() ast.Statement {
@@ -41,8 +56,10 @@ func (parser *Parser) parseStatement() ast.Statement {
 		return &ast.BadStatement{Start: parser.index, End: parser.index + 1}
 	case token.LEFT_BRACE:
 		return parser.parseBlockStatement()
-	case token.VAR:
-		return parser.parseVarStatement()
+	case token.VAR, token.LET, token.CONST:
+		varStatement := parser.parseVarStatement()
+		parser.checkConstInitializer(varStatement)
+		return varStatement
 	case token.FUN:
 		return parser.parseFunStatement()
 	case token.RETURN:
@@ -51,6 +68,10 @@ func (parser *Parser) parseStatement() ast.Statement {
 		return parser.parseIfStatement()
 	case token.FOR:
 		return parser.parseForStatement()
+	case token.WHILE:
+		return parser.parseWhileStatement()
+	case token.DO:
+		return parser.parseDoWhileStatement()
 	case token.SWITCH:
 		return parser.parseSwitchStatement()
 	case token.BREAK:
@@ -69,35 +90,58 @@ func (parser *Parser) parseStatement() ast.Statement {
 }
 
 func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
-	return &ast.BlockStatement{
+	blockStatement := &ast.BlockStatement{
 		LeftBrace: parser.expect(token.LEFT_BRACE),
 		Body: parser.parseStatementListByCondition(func(tkn token.Token) bool {
 			return tkn != token.RIGHT_BRACE && tkn != token.EOF
 		}),
-		RightBrace: parser.expect(token.RIGHT_BRACE),
 	}
+	parser.attachDanglingTrivia(blockStatement, parser.takeTrivia())
+	blockStatement.RightBrace = parser.expect(token.RIGHT_BRACE)
+	return blockStatement
 }
 
-func (parser *Parser) parseVarStatement() ast.Statement {
-	varIndex := parser.expect(token.VAR)
-	list := parser.parseVarDeclarationList(varIndex)
+func (parser *Parser) parseVarStatement() *ast.VarStatement {
+	tkn := parser.token
+	if tkn != token.LET && tkn != token.CONST {
+		tkn = token.VAR
+	}
+	varIndex := parser.expect(tkn)
+	list := parser.parseVarDeclarationList(varIndex, tkn)
 	return &ast.VarStatement{
-		Var:  varIndex,
-		List: list,
+		Var:   varIndex,
+		Token: tkn,
+		List:  list,
 	}
 }
 
-func (parser *Parser) parseVarDeclarationList(varIndex file.Index) []*ast.Binding {
+// parseVarDeclarationList hoists var declarations to the enclosing scope, let and
+// const declarations stay in the block that contains them.
+func (parser *Parser) parseVarDeclarationList(varIndex file.Index, tkn token.Token) []*ast.Binding {
 	bindingList := parser.parseBindingList()
 
-	parser.scope.AddDeclaration(&ast.VariableDeclaration{
-		Var:  varIndex,
-		List: bindingList,
-	})
+	if tkn == token.VAR {
+		parser.scope.AddDeclaration(&ast.VariableDeclaration{
+			Var:   varIndex,
+			Token: tkn,
+			List:  bindingList,
+		})
+	}
 
 	return bindingList
 }
 
+func (parser *Parser) checkConstInitializer(varStatement *ast.VarStatement) {
+	if varStatement.Token != token.CONST {
+		return
+	}
+	for _, binding := range varStatement.List {
+		if binding.Initializer == nil {
+			parser.errorNode(binding, ERR_MissingInitializer)
+		}
+	}
+}
+
 func (parser *Parser) parseFunStatement() ast.Statement {
 	funStatement := &ast.FunStatement{
 		FunLiteral: parser.parseFunLiteral(),
@@ -108,9 +152,9 @@ func (parser *Parser) parseFunStatement() ast.Statement {
 func (parser *Parser) parseReturnStatement() ast.Statement {
 	returnIndex := parser.expect(token.RETURN)
 	if !parser.scope.inFunction {
-		parser.error(returnIndex, "Illegal return statement")
-		parser.nextStatement()
-		return &ast.BadStatement{Start: returnIndex, End: parser.index}
+		parser.errorRange(returnIndex, returnIndex+6, ERR_IllegalReturn)
+		parser.parseReturnArguments()
+		return &ast.BadStatement{Start: returnIndex, End: parser.tokenEnd}
 	}
 	return &ast.ReturnStatement{
 		Return:    returnIndex,
@@ -136,12 +180,18 @@ func (parser *Parser) parseIfStatement() ast.Statement {
 func (parser *Parser) parseForStatement() ast.Statement {
 	parser.openScope()
 	defer parser.closeScope()
+	forIndex := parser.expect(token.FOR)
 	forStatement := &ast.ForStatement{
-		For: parser.expect(token.FOR),
+		For: forIndex,
 	}
 	if parser.token != token.LEFT_BRACE {
 		if parser.token != token.SEMICOLON {
-			forStatement.Initializer = parser.parseVarStatement()
+			varStatement := parser.parseVarStatement()
+			if parser.token == token.IN || parser.isOfKeyword() {
+				return parser.parseForInOrOfStatement(forIndex, varStatement)
+			}
+			parser.checkConstInitializer(varStatement)
+			forStatement.Initializer = varStatement
 		}
 		if parser.token == token.SEMICOLON {
 			parser.expect(token.SEMICOLON)
@@ -162,6 +212,85 @@ func (parser *Parser) parseForStatement() ast.Statement {
 	return forStatement
 }
 
+func (parser *Parser) isOfKeyword() bool {
+	return parser.token == token.IDENTIFIER && parser.literal == "of"
+}
+
+func (parser *Parser) parseForInOrOfStatement(forIndex file.Index, varStatement *ast.VarStatement) ast.Statement {
+	isOf := parser.isOfKeyword()
+	maxBindings := 2
+	if isOf {
+		maxBindings = 1
+	}
+	var identifiers []*ast.Identifier
+	for _, binding := range varStatement.List {
+		identifier, ok := binding.Target.(*ast.Identifier)
+		if !ok || binding.Initializer != nil {
+			parser.errorNode(binding, ERR_InvalidForInOf, parser.literal)
+			continue
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	if len(varStatement.List) > maxBindings {
+		parser.errorNode(varStatement, ERR_ForInOfBindings, parser.literal, maxBindings)
+	}
+	parser.next()
+	source := parser.parseExpression()
+	parser.scope.inIteration = true
+	body := parser.parseBlockStatement()
+	parser.scope.inIteration = false
+	if len(identifiers) == 0 {
+		return &ast.BadStatement{Start: forIndex, End: parser.index}
+	}
+	if isOf {
+		return &ast.ForOfStatement{
+			For:    forIndex,
+			Token:  varStatement.Token,
+			Value:  identifiers[0],
+			Source: source,
+			Body:   body,
+		}
+	}
+	forInStatement := &ast.ForInStatement{
+		For:    forIndex,
+		Token:  varStatement.Token,
+		Key:    identifiers[0],
+		Source: source,
+		Body:   body,
+	}
+	if len(identifiers) > 1 {
+		forInStatement.Value = identifiers[1]
+	}
+	return forInStatement
+}
+
+func (parser *Parser) parseWhileStatement() ast.Statement {
+	parser.openScope()
+	defer parser.closeScope()
+	whileStatement := &ast.WhileStatement{
+		While:     parser.expect(token.WHILE),
+		Condition: parser.parseExpression(),
+	}
+	parser.scope.inIteration = true
+	whileStatement.Body = parser.parseBlockStatement()
+	parser.scope.inIteration = false
+	return whileStatement
+}
+
+func (parser *Parser) parseDoWhileStatement() ast.Statement {
+	parser.openScope()
+	defer parser.closeScope()
+	doWhileStatement := &ast.DoWhileStatement{
+		Do: parser.expect(token.DO),
+	}
+	parser.scope.inIteration = true
+	doWhileStatement.Body = parser.parseBlockStatement()
+	parser.scope.inIteration = false
+	parser.expect(token.WHILE)
+	doWhileStatement.Condition = parser.parseExpression()
+	return doWhileStatement
+}
+
 func (parser *Parser) parseSwitchStatement() ast.Statement {
 	switchStatement := &ast.SwitchStatement{
 		Switch:       parser.expect(token.SWITCH),
@@ -171,6 +300,7 @@ func (parser *Parser) parseSwitchStatement() ast.Statement {
 	parser.scope.inSwitch = true
 	switchStatement.Body, switchStatement.Default = parser.parseCaseStatementList()
 	parser.scope.inSwitch = false
+	parser.attachDanglingTrivia(switchStatement, parser.takeTrivia())
 	switchStatement.RightBrace = parser.expect(token.RIGHT_BRACE)
 	return switchStatement
 }
@@ -180,13 +310,19 @@ func (parser *Parser) parseCaseStatementList() ([]*ast.CaseStatement, int) {
 	var defaultIndex = -1
 	parser.expect(token.LEFT_BRACE)
 	for index := 0; parser.token != token.RIGHT_BRACE && parser.token != token.EOF; index++ {
+		leading := parser.takeTrivia()
+		caseIndex := parser.index
 		caseStatement := parser.parseCaseStatement()
+		parser.attachTrivia(caseStatement, leading)
+		if parser.recovering {
+			parser.synchronizeStatement(caseIndex)
+		}
 		caseStatementList = append(caseStatementList, caseStatement)
 		if caseStatement.Condition == nil {
 			if defaultIndex == -1 {
 				defaultIndex = index
 			} else {
-				parser.error(caseStatement.StartIndex(), "Already saw a default in switch")
+				parser.errorRange(caseStatement.Case, caseStatement.Case+7, ERR_DuplicateDefault)
 			}
 		}
 	}
@@ -210,25 +346,60 @@ func (parser *Parser) parseCaseStatement() *ast.CaseStatement {
 
 func (parser *Parser) parseBreakStatement() ast.Statement {
 	breakIndex := parser.expect(token.BREAK)
-	if !parser.scope.inIteration {
-		parser.error(breakIndex, "Illegal break statement")
-		parser.nextStatement()
-		return &ast.BadStatement{Start: breakIndex, End: parser.index}
+	label := parser.parseBranchLabel(breakIndex + 5)
+	if label != nil {
+		if !parser.scope.hasLabel(label.Name) {
+			parser.errorNode(label, ERR_UndefinedLabel, label.Name)
+			return &ast.BadStatement{Start: breakIndex, End: label.EndIndex()}
+		}
+	} else if !parser.scope.inIteration {
+		parser.errorRange(breakIndex, breakIndex+5, ERR_IllegalBreak)
+		return &ast.BadStatement{Start: breakIndex, End: breakIndex + 5}
 	}
 	return &ast.BreakStatement{
 		Break: breakIndex,
+		Label: label,
 	}
 }
 
 func (parser *Parser) parseContinueStatement() ast.Statement {
 	continueIndex := parser.expect(token.CONTINUE)
+	label := parser.parseBranchLabel(continueIndex + 8)
 	if !parser.scope.inIteration {
-		parser.error(continueIndex, "Illegal continue statement")
-		parser.nextStatement()
-		return &ast.BadStatement{Start: continueIndex, End: parser.index}
+		parser.errorRange(continueIndex, continueIndex+8, ERR_IllegalContinue)
+		return &ast.BadStatement{Start: continueIndex, End: parser.tokenEnd}
+	}
+	if label != nil && !parser.scope.hasLabel(label.Name) {
+		parser.errorNode(label, ERR_UndefinedLabel, label.Name)
+		return &ast.BadStatement{Start: continueIndex, End: label.EndIndex()}
 	}
 	return &ast.ContinueStatement{
 		Continue: continueIndex,
+		Label:    label,
+	}
+}
+
+// parseBranchLabel parses the optional label of a break or continue statement,
+// which has to start on the same line as the keyword ending at end.
+func (parser *Parser) parseBranchLabel(end file.Index) *ast.Identifier {
+	if parser.token != token.IDENTIFIER || strings.ContainsAny(parser.slice(end, parser.index), "\n\r") {
+		return nil
+	}
+	return parser.parseIdentifier()
+}
+
+func (parser *Parser) parseLabelledStatement(label *ast.Identifier) ast.Statement {
+	colon := parser.expect(token.COLON)
+	if parser.scope.hasLabel(label.Name) {
+		parser.errorNode(label, ERR_DuplicateLabel, label.Name)
+	}
+	parser.scope.labels = append(parser.scope.labels, label.Name)
+	statement := parser.parseStatement()
+	parser.scope.labels = parser.scope.labels[:len(parser.scope.labels)-1]
+	return &ast.LabelledStatement{
+		Label:     label,
+		Colon:     colon,
+		Statement: statement,
 	}
 }
 
@@ -283,6 +454,7 @@ func (parser *Parser) parseClassDeclaration() ast.Statement {
 
 	classDeclaration.LeftBrace = parser.expect(token.LEFT_BRACE)
 	classDeclaration.Body = parser.parseDeclarations()
+	parser.attachDanglingTrivia(classDeclaration, parser.takeTrivia())
 	classDeclaration.RightBrace = parser.expect(token.RIGHT_BRACE)
 	classDeclaration.ClassDefinition = parser.slice(classDeclaration.StartIndex(), classDeclaration.EndIndex())
 	return classDeclaration
@@ -290,7 +462,14 @@ func (parser *Parser) parseClassDeclaration() ast.Statement {
 
 func (parser *Parser) parseDeclarations() (declarations []ast.Declaration) {
 	for parser.token != token.RIGHT_BRACE && parser.token != token.EOF {
-		declarations = append(declarations, parser.parseDeclaration())
+		leading := parser.takeTrivia()
+		index := parser.index
+		declaration := parser.parseDeclaration()
+		parser.attachTrivia(declaration, leading)
+		declarations = append(declarations, declaration)
+		if parser.recovering {
+			parser.synchronizeDeclaration(index)
+		}
 	}
 	return
 }
@@ -306,7 +485,8 @@ func (parser *Parser) parseDeclaration() ast.Declaration {
 		sbdecl.Source = parser.slice(sbdecl.StartIndex(), sbdecl.EndIndex())
 		return sbdecl
 	case token.PRIVATE, token.PROTECTED, token.PUBLIC:
-		index := parser.expect(parser.token)
+		accessModifier := parser.token
+		index := parser.expect(accessModifier)
 		static := false
 		if parser.token == token.STATIC {
 			parser.expect(token.STATIC)
@@ -320,14 +500,14 @@ func (parser *Parser) parseDeclaration() ast.Declaration {
 			}
 			return &ast.MethodDeclaration{
 				Index:          index,
-				AccessModifier: parser.token,
+				AccessModifier: accessModifier,
 				Static:         static,
 				Body:           parser.parseAnonymousFunLiteral(funLiteral),
 			}
 		} else {
 			fieldDeclaration := &ast.FieldDeclaration{
 				Index:          index,
-				AccessModifier: parser.token,
+				AccessModifier: accessModifier,
 				Static:         static,
 				Name:           name,
 			}
@@ -338,17 +518,35 @@ func (parser *Parser) parseDeclaration() ast.Declaration {
 			return fieldDeclaration
 		}
 	default:
-		parser.error(parser.index, "Illegal break declaration")
-		parser.nextStatement()
+		parser.errorUnexpectedToken(parser.token)
 		return &ast.BadDeclaration{
 			Start: index,
-			End:   parser.index,
+			End:   parser.tokenEndOf(index),
 		}
 	}
 }
 
+// synchronizeDeclaration skips the rest of a class member that failed to parse
+// from index, up to the next member modifier or the start of the next line.
+func (parser *Parser) synchronizeDeclaration(index file.Index) {
+	if parser.index == index {
+		parser.next()
+	}
+	parser.synchronize(func() bool {
+		switch parser.token {
+		case token.PRIVATE, token.PROTECTED, token.PUBLIC, token.STATIC:
+			return true
+		}
+		return parser.onNewLine()
+	})
+}
+
 func (parser *Parser) parseExpressionStatement() ast.Statement {
+	expression := parser.parseExpression()
+	if identifier, ok := expression.(*ast.Identifier); ok && parser.token == token.COLON {
+		return parser.parseLabelledStatement(identifier)
+	}
 	return &ast.ExpressionStatement{
-		Expression: parser.parseExpression(),
+		Expression: expression,
 	}
 }
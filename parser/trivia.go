@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const lineTerminators = "\r\n\u2028\u2029"
+
+func (parser *Parser) keepTrivia() bool {
+	return !parser.skipComment || !parser.skipWhiteSpace
+}
+
+// addTrivia records the current comment or white space token. Consecutive
+// white space is merged into one run that ends after a line terminator.
+func (parser *Parser) addTrivia() {
+	literal := parser.slice(parser.index, parser.IndexOf(parser.chrOffset))
+	if parser.token == token.WHITE_SPACE && len(parser.pendingTrivia) > 0 {
+		last := parser.pendingTrivia[len(parser.pendingTrivia)-1]
+		if last.Token == token.WHITE_SPACE && (!endsLine(last.Literal) || strings.HasSuffix(last.Literal, "\r") && literal == "\n") {
+			last.Literal += literal
+			return
+		}
+	}
+	trivia := &ast.Trivia{
+		Token:   parser.token,
+		Index:   parser.index,
+		Literal: literal,
+	}
+	parser.trivia = append(parser.trivia, trivia)
+	parser.pendingTrivia = append(parser.pendingTrivia, trivia)
+}
+
+func endsLine(literal string) bool {
+	chr, _ := utf8.DecodeLastRuneInString(literal)
+	return strings.ContainsRune(lineTerminators, chr)
+}
+
+// takeTrivia claims the trivia in front of the current token.
+func (parser *Parser) takeTrivia() []*ast.Trivia {
+	trivia := parser.pendingTrivia
+	parser.pendingTrivia = nil
+	return trivia
+}
+
+// takeTrailingTrivia claims the trivia in front of the current token that is
+// on the same line as the end of the previous token.
+func (parser *Parser) takeTrailingTrivia() []*ast.Trivia {
+	count := 0
+	for _, trivia := range parser.pendingTrivia {
+		if strings.ContainsAny(parser.slice(parser.tokenEnd, trivia.Index), lineTerminators) ||
+			trivia.Token == token.WHITE_SPACE && strings.ContainsAny(trivia.Literal, lineTerminators) {
+			break
+		}
+		count++
+	}
+	trailing := parser.pendingTrivia[:count:count]
+	parser.pendingTrivia = parser.pendingTrivia[count:]
+	if len(trailing) == 0 {
+		return nil
+	}
+	return trailing
+}
+
+func (parser *Parser) nodeTrivia(node ast.Node) *ast.NodeTrivia {
+	nodeTrivia, exists := parser.triviaMap[node]
+	if !exists {
+		nodeTrivia = &ast.NodeTrivia{}
+		parser.triviaMap[node] = nodeTrivia
+	}
+	return nodeTrivia
+}
+
+func (parser *Parser) attachTrivia(node ast.Node, leading []*ast.Trivia) {
+	if !parser.keepTrivia() {
+		return
+	}
+	trailing := parser.takeTrailingTrivia()
+	if len(leading) > 0 || len(trailing) > 0 {
+		nodeTrivia := parser.nodeTrivia(node)
+		nodeTrivia.Leading = leading
+		nodeTrivia.Trailing = trailing
+	}
+}
+
+// attachDanglingTrivia attaches the trivia in front of the closing token of node.
+func (parser *Parser) attachDanglingTrivia(node ast.Node, dangling []*ast.Trivia) {
+	if parser.keepTrivia() && len(dangling) > 0 {
+		parser.nodeTrivia(node).Dangling = dangling
+	}
+}
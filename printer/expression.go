@@ -0,0 +1,341 @@
+package printer
+
+import (
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/token"
+)
+
+// The precedences follow the parser, an operand binding weaker than its
+// position requires is put in parentheses.
+const (
+	precedenceAssign = iota + 1
+	precedenceConditional
+	precedenceLogicalOr
+	precedenceLogicalAnd
+	precedenceBitwiseOr
+	precedenceBitwiseExclusiveOr
+	precedenceBitwiseAnd
+	precedenceEquality
+	precedenceRelational
+	precedenceShift
+	precedenceAdditive
+	precedenceMultiplicative
+	precedenceUnary
+	precedenceExponentiation
+	precedencePostfix
+	precedenceCall
+	precedencePrimary
+)
+
+func binaryPrecedence(operator token.Token) int {
+	switch operator {
+	case token.LOGICAL_OR:
+		return precedenceLogicalOr
+	case token.LOGICAL_AND:
+		return precedenceLogicalAnd
+	case token.OR_ARITHMETIC:
+		return precedenceBitwiseOr
+	case token.XOR_ARITHMETIC:
+		return precedenceBitwiseExclusiveOr
+	case token.AND_ARITHMETIC:
+		return precedenceBitwiseAnd
+	case token.EQUAL, token.NOT_EQUAL:
+		return precedenceEquality
+	case token.LESS, token.LESS_OR_EQUAL, token.GREATER, token.GREATER_OR_EQUAL:
+		return precedenceRelational
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT, token.UNSIGNED_SHIFT_RIGHT:
+		return precedenceShift
+	case token.ADDITION, token.SUBTRACT:
+		return precedenceAdditive
+	case token.MULTIPLY, token.DIVIDE, token.REMAINDER:
+		return precedenceMultiplicative
+	case token.EXPONENT:
+		return precedenceExponentiation
+	}
+	return precedenceAssign
+}
+
+func expressionPrecedence(expression ast.Expression) int {
+	switch expression := expression.(type) {
+	case *ast.AssignExpression, *ast.ArrowFunctionLiteral:
+		return precedenceAssign
+	case *ast.ConditionalExpression:
+		return precedenceConditional
+	case *ast.BinaryExpression:
+		return binaryPrecedence(expression.Operator)
+	case *ast.UnaryExpression:
+		if expression.Postfix || expression.Operator == token.INCREMENT || expression.Operator == token.DECREMENT {
+			return precedencePostfix
+		}
+		return precedenceUnary
+	case *ast.CallExpression, *ast.NewExpression, *ast.DotExpression, *ast.BracketExpression:
+		return precedenceCall
+	case *ast.TemplateLiteral:
+		if expression.Tag != nil {
+			return precedenceCall
+		}
+	}
+	return precedencePrimary
+}
+
+// leftmost returns the operand an expression starts with.
+func leftmost(expression ast.Expression) ast.Expression {
+	for {
+		switch node := expression.(type) {
+		case *ast.AssignExpression:
+			expression = node.Left
+		case *ast.ConditionalExpression:
+			expression = node.Test
+		case *ast.BinaryExpression:
+			expression = node.Left
+		case *ast.CallExpression:
+			expression = node.Callee
+		case *ast.DotExpression:
+			expression = node.Left
+		case *ast.BracketExpression:
+			expression = node.Left
+		case *ast.UnaryExpression:
+			if !node.Postfix {
+				return node
+			}
+			expression = node.Operand
+		case *ast.TemplateLiteral:
+			if node.Tag == nil {
+				return node
+			}
+			expression = node.Tag
+		default:
+			return expression
+		}
+	}
+}
+
+// expression prints an expression in a position that needs at least the
+// given precedence.
+func (self *printer) expression(expression ast.Expression, precedence int) {
+	self.expressionComments(self.leading[expression], false)
+	defer self.expressionComments(self.trailing[expression], true)
+	if expressionPrecedence(expression) < precedence {
+		self.write("(")
+		defer self.write(")")
+	}
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		self.write(expression.Name)
+	case *ast.NumberLiteral:
+		self.write(expression.Literal)
+	case *ast.StringLiteral:
+		self.write(expression.Literal)
+	case *ast.BooleanLiteral:
+		if expression.Value {
+			self.write("true")
+		} else {
+			self.write("false")
+		}
+	case *ast.NullLiteral:
+		self.write("null")
+	case *ast.ThisExpression:
+		self.write("this")
+	case *ast.TemplateLiteral:
+		if expression.Tag != nil {
+			self.expression(expression.Tag, precedenceCall)
+		}
+		self.write("`")
+		for i, element := range expression.Elements {
+			self.write(element.Literal)
+			if i < len(expression.Expressions) {
+				self.write("${")
+				self.expression(expression.Expressions[i], precedenceAssign)
+				self.write("}")
+			}
+		}
+		self.write("`")
+	case *ast.ArrayLiteral:
+		var first ast.Node
+		if len(expression.Values) > 0 {
+			first = expression.Values[0]
+		}
+		self.list("[", expression.Values, "]", self.multiline(expression.LeftBracket, first))
+	case *ast.ObjectLiteral:
+		var first ast.Node
+		if len(expression.Properties) > 0 {
+			first = expression.Properties[0]
+		}
+		properties := make([]ast.Expression, len(expression.Properties))
+		for i, property := range expression.Properties {
+			properties[i] = property
+		}
+		self.list("{", properties, "}", self.multiline(expression.LeftBrace, first))
+	case *ast.PropertyKeyValue:
+		self.write(expression.Name.Name + ": ")
+		self.expression(expression.Value, precedenceAssign)
+	case *ast.FunLiteral:
+		self.write("fun")
+		if expression.Name != nil {
+			self.write(" " + expression.Name.Name)
+		}
+		self.parameters(expression.ParameterList)
+		self.write(" ")
+		self.block(expression.Body)
+	case *ast.ArrowFunctionLiteral:
+		self.parameters(expression.ParameterList)
+		self.write(" -> ")
+		if body := conciseBody(expression.Body); body != nil {
+			if _, ok := body.(*ast.ObjectLiteral); ok {
+				// Braces after the arrow start a block.
+				self.write("(")
+				defer self.write(")")
+			}
+			self.expression(body, precedenceAssign)
+		} else {
+			self.block(expression.Body)
+		}
+	case *ast.AssignExpression:
+		self.expression(expression.Left, precedenceCall)
+		if expression.Operator == token.ASSIGN {
+			self.write(" = ")
+		} else {
+			self.write(" " + expression.Operator.String() + "= ")
+		}
+		self.expression(expression.Right, precedenceAssign)
+	case *ast.ConditionalExpression:
+		self.expression(expression.Test, precedenceLogicalOr)
+		self.write(" ? ")
+		self.expression(expression.Consequent, precedenceAssign)
+		self.write(" : ")
+		self.expression(expression.Alternate, precedenceAssign)
+	case *ast.BinaryExpression:
+		precedence := binaryPrecedence(expression.Operator)
+		left, right := precedence, precedence+1
+		switch precedence {
+		case precedenceExponentiation:
+			left, right = precedencePostfix, precedenceUnary
+		case precedenceRelational:
+			left, right = precedenceShift, precedenceShift
+		}
+		self.expression(expression.Left, left)
+		self.write(" " + expression.Operator.String() + " ")
+		self.expression(expression.Right, right)
+	case *ast.UnaryExpression:
+		if expression.Postfix {
+			self.expression(expression.Operand, precedenceCall)
+			self.write(expression.Operator.String())
+			break
+		}
+		self.write(expression.Operator.String())
+		if operand, ok := expression.Operand.(*ast.UnaryExpression); ok && !operand.Postfix &&
+			isSign(expression.Operator) && isSign(operand.Operator) {
+			// Keep - -a apart from --a.
+			self.write(" ")
+		}
+		self.expression(expression.Operand, precedenceUnary)
+	case *ast.DotExpression:
+		self.expression(expression.Left, precedenceCall)
+		self.write("." + expression.Identifier.Name)
+	case *ast.BracketExpression:
+		self.expression(expression.Left, precedenceCall)
+		self.write("[")
+		self.expression(expression.Expression, precedenceAssign)
+		self.write("]")
+	case *ast.CallExpression:
+		self.expression(expression.Callee, precedenceCall)
+		self.list("(", expression.Arguments, ")", false)
+	case *ast.NewExpression:
+		self.write("new ")
+		if callsOnLeft(expression.Callee) {
+			// The first arguments after new end its callee.
+			self.write("(")
+			self.expression(expression.Callee, precedenceCall)
+			self.write(")")
+		} else {
+			self.expression(expression.Callee, precedenceCall)
+		}
+		self.list("(", expression.Arguments, ")", false)
+	case *ast.BadExpression:
+		self.write(self.source(expression.Start, expression.End))
+	}
+}
+
+// expressionComments prints block comments in place, before or after an
+// expression. A line comment before an expression gets a line of its own, one
+// after an expression is kept for the end of the line.
+func (self *printer) expressionComments(triviaList []*ast.Trivia, after bool) {
+	for _, trivia := range triviaList {
+		switch {
+		case trivia.Token == token.COMMENT && after:
+			self.pending = append(self.pending, trivia)
+		case trivia.Token == token.COMMENT:
+			self.breakLine()
+			self.comment(trivia)
+			self.newline()
+		case after:
+			self.write(" ")
+			self.comment(trivia)
+		default:
+			self.comment(trivia)
+			self.write(" ")
+		}
+	}
+}
+
+func isSign(operator token.Token) bool {
+	switch operator {
+	case token.ADDITION, token.SUBTRACT, token.INCREMENT, token.DECREMENT:
+		return true
+	}
+	return false
+}
+
+// conciseBody returns the expression of an arrow function written without
+// braces, the parser wraps it in a block starting at the expression.
+func conciseBody(body *ast.BlockStatement) ast.Expression {
+	if len(body.Body) != 1 {
+		return nil
+	}
+	if statement, ok := body.Body[0].(*ast.ExpressionStatement); ok && statement.Expression.StartIndex() == body.LeftBrace {
+		return statement.Expression
+	}
+	return nil
+}
+
+// list prints comma separated expressions between brackets, on one line or
+// one per line.
+func (self *printer) list(open string, expressions []ast.Expression, close string, multiline bool) {
+	self.write(open)
+	if multiline {
+		self.newline()
+		self.indent++
+	}
+	for i, expression := range expressions {
+		self.expression(expression, precedenceAssign)
+		if i < len(expressions)-1 {
+			self.write(",")
+			if !multiline {
+				self.write(" ")
+			}
+		}
+		if multiline {
+			self.newline()
+		}
+	}
+	if multiline {
+		self.indent--
+	}
+	self.write(close)
+}
+
+// callsOnLeft reports whether a call is part of the callee chain of expression.
+func callsOnLeft(expression ast.Expression) bool {
+	for {
+		switch node := expression.(type) {
+		case *ast.CallExpression:
+			return true
+		case *ast.DotExpression:
+			expression = node.Left
+		case *ast.BracketExpression:
+			expression = node.Left
+		default:
+			return false
+		}
+	}
+}
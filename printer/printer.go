@@ -0,0 +1,357 @@
+package printer
+
+import (
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/file"
+	"github.com/istrangers/demolanguage/parser"
+	"github.com/istrangers/demolanguage/token"
+	"sort"
+	"strings"
+)
+
+const indentation = "    "
+
+type printer struct {
+	program   *ast.Program
+	builder   strings.Builder
+	indent    int
+	lineStart bool
+
+	// comments holds the comments that are not attached to a node, by the
+	// statement, case or class member they are printed in front of.
+	comments map[ast.Node][]*ast.Trivia
+	// leading and trailing hold the comments inside an expression, by the
+	// nearest expression they are printed before or after.
+	leading  map[ast.Node][]*ast.Trivia
+	trailing map[ast.Node][]*ast.Trivia
+	// pending holds the line comments to print before the next line break.
+	pending []*ast.Trivia
+}
+
+// Format parses source in concrete syntax mode and prints it in canonical form.
+func Format(fileName string, source string) (string, error) {
+	program, err := parser.CreateParser(1, fileName, source, false, false).Parse()
+	if err != nil {
+		return "", err
+	}
+	return Print(program), nil
+}
+
+// Print returns the source of program in canonical form. Comments and blank
+// lines between statements are kept if program was parsed in concrete syntax
+// mode.
+func Print(program *ast.Program) string {
+	printer := &printer{
+		program:   program,
+		lineStart: true,
+	}
+	printer.placeComments()
+	printer.printList(statements(program.Body), func(node ast.Node) {
+		printer.statement(node.(ast.Statement))
+	})
+	printer.printDangling(append(program.Dangling, printer.comments[program]...), len(program.Body) > 0)
+	return printer.builder.String()
+}
+
+func statements(list []ast.Statement) []ast.Node {
+	nodes := make([]ast.Node, len(list))
+	for i, statement := range list {
+		nodes[i] = statement
+	}
+	return nodes
+}
+
+// placeComments assigns each comment the parser did not attach, like one
+// inside an expression, to the nearest expression of the innermost statement,
+// case or class member around it, or else to that statement or the statement
+// after it.
+func (self *printer) placeComments() {
+	self.comments = map[ast.Node][]*ast.Trivia{}
+	self.leading, self.trailing = map[ast.Node][]*ast.Trivia{}, map[ast.Node][]*ast.Trivia{}
+	attached := map[*ast.Trivia]bool{}
+	for _, trivia := range self.program.Dangling {
+		attached[trivia] = true
+	}
+	for _, nodeTrivia := range self.program.TriviaMap {
+		for _, list := range [][]*ast.Trivia{nodeTrivia.Leading, nodeTrivia.Trailing, nodeTrivia.Dangling} {
+			for _, trivia := range list {
+				attached[trivia] = true
+			}
+		}
+	}
+	var items []ast.Node
+	ast.Inspect(self.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			items = append(items, statements(node.Body)...)
+		case *ast.BlockStatement:
+			items = append(items, statements(node.Body)...)
+		case *ast.SwitchStatement:
+			for _, caseStatement := range node.Body {
+				items = append(items, caseStatement)
+			}
+		case *ast.ClassDeclaration:
+			for _, declaration := range node.Body {
+				items = append(items, declaration)
+			}
+		}
+		return true
+	})
+	for _, trivia := range self.program.Trivia {
+		if trivia.Token == token.WHITE_SPACE || attached[trivia] {
+			continue
+		}
+		var owner ast.Node
+		for _, item := range items {
+			if item.StartIndex() <= trivia.Index && trivia.Index < item.EndIndex() &&
+				(owner == nil || item.EndIndex()-item.StartIndex() < owner.EndIndex()-owner.StartIndex()) {
+				owner = item
+			}
+		}
+		if owner != nil && self.placeExpressionComment(owner, trivia) {
+			continue
+		}
+		if owner == nil {
+			index := sort.Search(len(self.program.Body), func(i int) bool {
+				return self.program.Body[i].StartIndex() > trivia.Index
+			})
+			owner = self.program
+			if index < len(self.program.Body) {
+				owner = self.program.Body[index]
+			}
+		}
+		self.comments[owner] = append(self.comments[owner], trivia)
+	}
+}
+
+// placeExpressionComment attaches a comment inside owner to the innermost
+// expression it follows on the same line or else to the one after it. A line
+// comment on a line of its own stays in front of the expression after it.
+func (self *printer) placeExpressionComment(owner ast.Node, trivia *ast.Trivia) bool {
+	var before, after ast.Node
+	for _, node := range printedExpressions(owner) {
+		if node.EndIndex() <= trivia.Index {
+			if before == nil || node.EndIndex() > before.EndIndex() ||
+				node.EndIndex() == before.EndIndex() && node.StartIndex() > before.StartIndex() {
+				before = node
+			}
+		} else if node.StartIndex() >= trivia.EndIndex() {
+			if after == nil || node.StartIndex() < after.StartIndex() ||
+				node.StartIndex() == after.StartIndex() && node.EndIndex() < after.EndIndex() {
+				after = node
+			}
+		}
+	}
+	between := ""
+	if before != nil {
+		between = self.source(before.EndIndex(), trivia.Index)
+	}
+	switch {
+	case before != nil && (after == nil || strings.TrimSpace(between) == "" ||
+		trivia.Token == token.COMMENT && !strings.ContainsAny(between, "\r\n\u2028\u2029")):
+		self.trailing[before] = append(self.trailing[before], trivia)
+	case after != nil:
+		self.leading[after] = append(self.leading[after], trivia)
+	default:
+		return false
+	}
+	return true
+}
+
+// printedExpressions returns the expressions of owner the printer prints with
+// expression, leaving out nested blocks, the identifiers written as names and
+// the parts of a node printed around its expressions.
+func printedExpressions(owner ast.Node) []ast.Node {
+	var nodes []ast.Node
+	skipped := map[ast.Node]bool{}
+	ast.Inspect(owner, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.BlockStatement, *ast.InterfaceDeclaration:
+			return node == owner
+		case *ast.ClassDeclaration:
+			if node != owner {
+				return false
+			}
+			skipped[node.Name], skipped[node.SuperClass] = true, true
+			for _, identifier := range node.Interfaces {
+				skipped[identifier] = true
+			}
+		case *ast.MethodDeclaration:
+			if node != owner {
+				return false
+			}
+			skipped[node.Body] = true
+		case *ast.Binding, *ast.ParameterList, *ast.TemplateElement:
+			skipped[node] = true
+		case *ast.DotExpression:
+			skipped[node.Identifier] = true
+		case *ast.PropertyKeyValue:
+			skipped[node.Name] = true
+		case *ast.FunLiteral:
+			skipped[node.Name] = true
+		case *ast.ForInStatement:
+			skipped[node.Key], skipped[node.Value] = true, true
+		case *ast.ForOfStatement:
+			skipped[node.Value] = true
+		case *ast.BreakStatement:
+			skipped[node.Label] = true
+		case *ast.ContinueStatement:
+			skipped[node.Label] = true
+		case *ast.LabelledStatement:
+			skipped[node.Label] = true
+		case *ast.FieldDeclaration:
+			skipped[node.Name] = true
+		}
+		if expression, ok := node.(ast.Expression); ok && !skipped[node] {
+			nodes = append(nodes, expression)
+		}
+		return true
+	})
+	return nodes
+}
+
+func (self *printer) write(text string) {
+	if self.lineStart && text != "" {
+		self.builder.WriteString(strings.Repeat(indentation, self.indent))
+		self.lineStart = false
+	}
+	self.builder.WriteString(text)
+}
+
+func (self *printer) newline() {
+	for _, trivia := range self.pending {
+		self.write(" ")
+		self.comment(trivia)
+	}
+	self.pending = nil
+	self.builder.WriteByte('\n')
+	self.lineStart = true
+}
+
+// breakLine moves to the start of a new line unless the printer is at one,
+// dropping the space written after the last token.
+func (self *printer) breakLine() {
+	if self.lineStart {
+		return
+	}
+	text := strings.TrimRight(self.builder.String(), " ")
+	self.builder.Reset()
+	self.builder.WriteString(text)
+	self.newline()
+}
+
+func (self *printer) comment(trivia *ast.Trivia) {
+	if trivia.Token == token.COMMENT {
+		self.write(strings.TrimRight(trivia.Literal, " \t"))
+	} else {
+		self.write(trivia.Literal)
+	}
+}
+
+func lineBreaks(literal string) int {
+	literal = strings.ReplaceAll(literal, "\r\n", "\n")
+	return strings.Count(literal, "\n") + strings.Count(literal, "\r") +
+		strings.Count(literal, "\u2028") + strings.Count(literal, "\u2029")
+}
+
+// printList prints the statements, cases or class members of a body, one per
+// line, with their comments and at most one blank line between them.
+func (self *printer) printList(nodes []ast.Node, print func(ast.Node)) {
+	for i, node := range nodes {
+		breaks, separate := 0, i > 0
+		for _, trivia := range self.program.TriviaMap.Leading(node) {
+			if trivia.Token == token.WHITE_SPACE {
+				breaks += lineBreaks(trivia.Literal)
+				continue
+			}
+			if breaks > 1 && separate {
+				self.newline()
+			}
+			self.comment(trivia)
+			self.newline()
+			breaks, separate = 0, true
+		}
+		if breaks > 1 && separate {
+			self.newline()
+		}
+		for _, trivia := range self.comments[node] {
+			self.comment(trivia)
+			self.newline()
+		}
+		print(node)
+		for _, trivia := range self.program.TriviaMap.Trailing(node) {
+			if trivia.Token != token.WHITE_SPACE {
+				self.write(" ")
+				self.comment(trivia)
+			}
+		}
+		self.newline()
+	}
+}
+
+// printDangling prints the comments after the last statement of a body.
+func (self *printer) printDangling(triviaList []*ast.Trivia, separate bool) {
+	breaks := 0
+	for _, trivia := range triviaList {
+		if trivia.Token == token.WHITE_SPACE {
+			breaks += lineBreaks(trivia.Literal)
+			continue
+		}
+		if breaks > 1 && separate {
+			self.newline()
+		}
+		self.comment(trivia)
+		self.newline()
+		breaks, separate = 0, true
+	}
+}
+
+func hasComments(triviaList []*ast.Trivia) bool {
+	for _, trivia := range triviaList {
+		if trivia.Token != token.WHITE_SPACE {
+			return true
+		}
+	}
+	return false
+}
+
+// body prints braces around the lines of a block, a switch or a class.
+func (self *printer) body(node ast.Node, nodes []ast.Node, print func(ast.Node)) {
+	dangling := self.program.TriviaMap.Dangling(node)
+	if len(nodes) == 0 && !hasComments(dangling) {
+		self.write("{}")
+		return
+	}
+	self.write("{")
+	self.newline()
+	self.indent++
+	self.printList(nodes, print)
+	self.printDangling(dangling, len(nodes) > 0)
+	self.indent--
+	self.write("}")
+}
+
+func (self *printer) block(blockStatement *ast.BlockStatement) {
+	self.body(blockStatement, statements(blockStatement.Body), func(node ast.Node) {
+		self.statement(node.(ast.Statement))
+	})
+}
+
+// source returns the original text between two indexes.
+func (self *printer) source(start, end file.Index) string {
+	if self.program.File == nil {
+		return ""
+	}
+	from := int(start) - self.program.File.BaseOffset
+	to := int(end) - self.program.File.BaseOffset
+	if from < 0 || to > len(self.program.File.Content) || from > to {
+		return ""
+	}
+	return self.program.File.Content[from:to]
+}
+
+// multiline reports whether the source breaks the line after the opening
+// bracket at open, which keeps an array or object literal on several lines.
+func (self *printer) multiline(open file.Index, first ast.Node) bool {
+	return first != nil && strings.ContainsAny(self.source(open, first.StartIndex()), "\r\n\u2028\u2029")
+}
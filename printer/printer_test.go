@@ -0,0 +1,80 @@
+package printer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatExamples(t *testing.T) {
+	fileNames, _ := filepath.Glob("../example/*.dl")
+	for _, fileName := range fileNames {
+		content, _ := os.ReadFile(fileName)
+		formatted, err := Format(fileName, string(content))
+		if err != nil {
+			t.Fatalf("%s: %v", fileName, err)
+		}
+		again, err := Format(fileName, formatted)
+		if err != nil {
+			t.Fatalf("%s: formatted source: %v", fileName, err)
+		}
+		if again != formatted {
+			t.Errorf("%s: formatting is not idempotent:\n%s", fileName, again)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var   a=1+2*3", "var a = 1 + 2 * 3\n"},
+		{"var a = (1 + 2) * 3 - (4 - 5)", "var a = (1 + 2) * 3 - (4 - 5)\n"},
+		{"var a = ((1 + 2))", "var a = 1 + 2\n"},
+		{"var a = -(-b)", "var a = - -b\n"},
+		{"var a = -(2 ** 2) + (-2) ** 2 + (++i) ** 2", "var a = -2 ** 2 + (-2) ** 2 + ++i ** 2\n"},
+		{"var f = x -> ({a: x})", "var f = (x) -> ({a: x})\n"},
+		{"var a = new (f().g)()", "var a = new (f().g)()\n"},
+		{"if a {b()} else if c {} else {d()}", "if a {\n    b()\n} else if c {} else {\n    d()\n}\n"},
+		{"for var i = 0;i<3;i++ {}\nfor {}", "for var i = 0; i < 3; i++ {}\nfor {}\n"},
+		{"switch a {\ncase 1 { b() }\ndefault {}\n}", "switch a {\n    case 1 {\n        b()\n    }\n    default {}\n}\n"},
+		{"var o = {a: 1, b: [1,2,],}", "var o = {a: 1, b: [1, 2]}\n"},
+		{"var o = {\n  a: 1}", "var o = {\n    a: 1\n}\n"},
+		{"class A extends B implements C {\npublic static n = 1\nprotected m(a) { return a }\n}",
+			"class A extends B implements C {\n    public static n = 1\n    protected m(a) {\n        return a\n    }\n}\n"},
+		{"try { f() } catch (e) {} finally { g() }", "try {\n    f()\n} catch (e) {} finally {\n    g()\n}\n"},
+		{
+			"// head\n\n\n\nvar a = 1  // one   \n\n/* two */\nvar b = f(1, /* arg */ 2)\nfun g() {\n\n    a()\n\n\n    // tail\n}\n// end\n",
+			"// head\n\nvar a = 1 // one\n\n/* two */\nvar b = f(1, /* arg */ 2)\nfun g() {\n    a()\n\n    // tail\n}\n// end\n",
+		},
+		{"var o = {a: /* one */ 1,  b: 2 /* two */}", "var o = {a: /* one */ 1, b: 2 /* two */}\n"},
+		{"var o = {\na: 1, // one\n// before b\nb: 2\n}", "var o = {\n    a: 1, // one\n    // before b\n    b: 2\n}\n"},
+		{"f(a /* first */,  /* second */ b)", "f(a /* first */, /* second */ b)\n"},
+		{"var s = a /* left */ + /* right */ b * c", "var s = a /* left */ + /* right */ b * c\n"},
+		{"var s = (a + b /* sum */) * c", "var s = (a + b /* sum */) * c\n"},
+		{"x = f(1, // one\n2)", "x = f(1, 2) // one\n"},
+		{"x = f(1,\n// two\n2)", "x = f(1,\n// two\n2)\n"},
+		{"fun g(a, /* b */ b) { return /* r */ a }", "fun g(a, /* b */ b) {\n    return /* r */ a\n}\n"},
+		{"if a /* test */ {\nb(/* none */)\n}", "if a /* test */ {\n    b /* none */()\n}\n"},
+	}
+	for _, test := range tests {
+		formatted, err := Format("test.dl", test.source)
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		if formatted != test.want {
+			t.Errorf("%q:\n%s\nwant:\n%s", test.source, formatted, test.want)
+		}
+		if again, _ := Format("test.dl", formatted); again != formatted {
+			t.Errorf("%q: not idempotent:\n%s", test.source, again)
+		}
+	}
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	if _, err := Format("test.dl", "var a = "); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
@@ -0,0 +1,224 @@
+package printer
+
+import (
+	"github.com/istrangers/demolanguage/ast"
+	"github.com/istrangers/demolanguage/token"
+)
+
+func (self *printer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		// A statement starting with a function or an object literal would be
+		// read as a declaration or a block.
+		switch leftmost(statement.Expression).(type) {
+		case *ast.FunLiteral, *ast.ObjectLiteral:
+			self.write("(")
+			self.expression(statement.Expression, precedenceAssign)
+			self.write(")")
+		default:
+			self.expression(statement.Expression, precedenceAssign)
+		}
+	case *ast.BlockStatement:
+		self.block(statement)
+	case *ast.VarStatement:
+		self.write(statement.Token.String() + " ")
+		self.bindings(statement.List)
+	case *ast.FunStatement:
+		self.expression(statement.FunLiteral, precedenceAssign)
+	case *ast.ReturnStatement:
+		self.write("return")
+		for i, argument := range statement.Arguments {
+			if i == 0 {
+				self.write(" ")
+			} else {
+				self.write(", ")
+			}
+			self.expression(argument, precedenceAssign)
+		}
+	case *ast.IfStatement:
+		self.write("if ")
+		self.expression(statement.Condition, precedenceAssign)
+		self.write(" ")
+		self.statement(statement.Consequent)
+		if statement.Alternate != nil {
+			self.write(" else ")
+			self.statement(statement.Alternate)
+		}
+	case *ast.ForStatement:
+		self.write("for ")
+		if statement.Initializer != nil || statement.Condition != nil || statement.Update != nil {
+			if statement.Initializer != nil {
+				self.statement(statement.Initializer)
+			}
+			self.write(";")
+			if statement.Condition != nil {
+				self.write(" ")
+				self.expression(statement.Condition, precedenceAssign)
+			}
+			self.write(";")
+			if statement.Update != nil {
+				self.write(" ")
+				self.expression(statement.Update, precedenceAssign)
+			}
+			self.write(" ")
+		}
+		self.statement(statement.Body)
+	case *ast.ForInStatement:
+		self.write("for " + statement.Token.String() + " " + statement.Key.Name)
+		if statement.Value != nil {
+			self.write(", " + statement.Value.Name)
+		}
+		self.write(" in ")
+		self.expression(statement.Source, precedenceAssign)
+		self.write(" ")
+		self.statement(statement.Body)
+	case *ast.ForOfStatement:
+		self.write("for " + statement.Token.String() + " " + statement.Value.Name + " of ")
+		self.expression(statement.Source, precedenceAssign)
+		self.write(" ")
+		self.statement(statement.Body)
+	case *ast.WhileStatement:
+		self.write("while ")
+		self.expression(statement.Condition, precedenceAssign)
+		self.write(" ")
+		self.statement(statement.Body)
+	case *ast.DoWhileStatement:
+		self.write("do ")
+		self.statement(statement.Body)
+		self.write(" while ")
+		self.expression(statement.Condition, precedenceAssign)
+	case *ast.SwitchStatement:
+		self.write("switch ")
+		self.expression(statement.Discriminant, precedenceAssign)
+		self.write(" ")
+		cases := make([]ast.Node, len(statement.Body))
+		for i, caseStatement := range statement.Body {
+			cases[i] = caseStatement
+		}
+		self.body(statement, cases, func(node ast.Node) {
+			self.statement(node.(*ast.CaseStatement))
+		})
+	case *ast.CaseStatement:
+		if statement.Condition != nil {
+			self.write("case ")
+			self.expression(statement.Condition, precedenceAssign)
+			self.write(" ")
+		} else {
+			self.write("default ")
+		}
+		self.statement(statement.Consequent)
+	case *ast.BreakStatement:
+		self.write("break")
+		if statement.Label != nil {
+			self.write(" " + statement.Label.Name)
+		}
+	case *ast.ContinueStatement:
+		self.write("continue")
+		if statement.Label != nil {
+			self.write(" " + statement.Label.Name)
+		}
+	case *ast.LabelledStatement:
+		self.write(statement.Label.Name + ": ")
+		self.statement(statement.Statement)
+	case *ast.ThrowStatement:
+		self.write("throw ")
+		self.expression(statement.Argument, precedenceAssign)
+	case *ast.TryCatchFinallyStatement:
+		self.write("try ")
+		self.block(statement.TryBody)
+		if statement.CatchBody != nil {
+			self.write(" catch ")
+			self.parameters(statement.CatchParameters)
+			self.write(" ")
+			self.block(statement.CatchBody)
+		}
+		if statement.FinallyBody != nil {
+			self.write(" finally ")
+			self.block(statement.FinallyBody)
+		}
+	case *ast.ClassDeclaration:
+		self.write("class " + statement.Name.Name)
+		if statement.SuperClass != nil {
+			self.write(" extends " + statement.SuperClass.Name)
+		}
+		for i, identifier := range statement.Interfaces {
+			if i == 0 {
+				self.write(" implements ")
+			} else {
+				self.write(", ")
+			}
+			self.write(identifier.Name)
+		}
+		self.write(" ")
+		self.declarations(statement, statement.Body)
+	case *ast.InterfaceDeclaration:
+		self.write("interface ")
+		self.declarations(statement, statement.Body)
+	case *ast.BadStatement:
+		self.write(self.source(statement.Start, statement.End))
+	default:
+		if declaration, ok := statement.(ast.Declaration); ok {
+			self.declaration(declaration)
+		}
+	}
+}
+
+func (self *printer) declarations(node ast.Node, declarations []ast.Declaration) {
+	nodes := make([]ast.Node, len(declarations))
+	for i, declaration := range declarations {
+		nodes[i] = declaration
+	}
+	self.body(node, nodes, func(node ast.Node) {
+		self.declaration(node.(ast.Declaration))
+	})
+}
+
+func (self *printer) declaration(declaration ast.Declaration) {
+	modifiers := func(accessModifier token.Token, static bool) {
+		self.write(accessModifier.String() + " ")
+		if static {
+			self.write("static ")
+		}
+	}
+	switch declaration := declaration.(type) {
+	case *ast.FieldDeclaration:
+		modifiers(declaration.AccessModifier, declaration.Static)
+		self.write(declaration.Name.Name)
+		if declaration.Initializer != nil {
+			self.write(" = ")
+			self.expression(declaration.Initializer, precedenceAssign)
+		}
+	case *ast.MethodDeclaration:
+		modifiers(declaration.AccessModifier, declaration.Static)
+		self.write(declaration.Body.Name.Name)
+		self.parameters(declaration.Body.ParameterList)
+		self.write(" ")
+		self.block(declaration.Body.Body)
+	case *ast.StaticBlockDeclaration:
+		self.write("static ")
+		self.block(declaration.Body)
+	case *ast.BadDeclaration:
+		self.write(self.source(declaration.Start, declaration.End))
+	}
+}
+
+func (self *printer) bindings(bindings []*ast.Binding) {
+	for i, binding := range bindings {
+		if i > 0 {
+			self.write(", ")
+		}
+		self.expression(binding.Target, precedenceAssign)
+		if binding.Initializer != nil {
+			self.write(" = ")
+			self.expression(binding.Initializer, precedenceAssign)
+		}
+	}
+}
+
+func (self *printer) parameters(parameterList *ast.ParameterList) {
+	self.write("(")
+	if parameterList != nil {
+		self.bindings(parameterList.List)
+	}
+	self.write(")")
+}
@@ -1,28 +1,49 @@
-package main
+package repl
 
 import (
 	"bufio"
 	"fmt"
 	"github.com/istrangers/demolanguage/vm"
-	"os"
+	"io"
+	"strings"
 )
 
-func main() {
-	reader := bufio.NewReader(os.Stdin)
-	vm := vm.CreateVM()
+// FileName names the input of a session in errors and stack traces.
+const FileName = "<repl>"
+
+func Start(in io.Reader, out io.Writer) {
+	reader := bufio.NewReader(in)
+	machine := vm.CreateVM()
+	line := 0
 	for {
-		fmt.Print(">")
+		fmt.Fprint(out, ">")
 		content, err := reader.ReadString('\n')
-		if err != nil {
-			panic(err)
+		if err != nil && err != io.EOF {
+			fmt.Fprintln(out, err)
+			return
 		}
-		result, err := vm.RunScript(content)
-		if err != nil {
-			panic(err)
+		if content != "" {
+			// Each input is padded to its line in the session, so positions
+			// count lines across the session.
+			result, runErr := run(machine, strings.Repeat("\n", line)+content)
+			if runErr != nil {
+				fmt.Fprintln(out, runErr)
+			} else if result != nil {
+				fmt.Fprintln(out, vm.Literal(result))
+			}
+			line += strings.Count(content, "\n")
 		}
-		if result == nil {
-			continue
+		if err == io.EOF {
+			fmt.Fprintln(out)
+			return
 		}
-		fmt.Printf("%v\n", result)
 	}
 }
+
+func run(machine *vm.VM, script string) (vm.Value, error) {
+	program, err := vm.Compile(FileName, script)
+	if err != nil {
+		return nil, err
+	}
+	return machine.RunProgram(program)
+}
@@ -24,8 +24,10 @@ const (
 	DOT               // .
 	COMMA             // ,
 	COLON             // :
+	QUESTION          // ?
 	SEMICOLON         // ;
 	ARROW             // ->
+	BACKTICK          // `
 
 	NUMBER
 	STRING
@@ -37,8 +39,14 @@ const (
 	MULTIPLY              // *
 	DIVIDE                // /
 	REMAINDER             // %
+	EXPONENT              // **
 	AND_ARITHMETIC        // &
 	OR_ARITHMETIC         // |
+	XOR_ARITHMETIC        // ^
+	BITWISE_NOT           // ~
+	SHIFT_LEFT            // <<
+	SHIFT_RIGHT           // >>
+	UNSIGNED_SHIFT_RIGHT  // >>>
 	INCREMENT             // ++
 	DECREMENT             // --
 	ADDITION_ASSIGN       // +=
@@ -46,8 +54,14 @@ const (
 	MULTIPLY_ASSIGN       // *=
 	DIVIDE_ASSIGN         // /=
 	REMAINDER_ASSIGN      // %=
+	EXPONENT_ASSIGN       // **=
 	AND_ARITHMETIC_ASSIGN // &=
 	OR_ARITHMETIC_ASSIGN  // |=
+	XOR_ARITHMETIC_ASSIGN // ^=
+
+	SHIFT_LEFT_ASSIGN           // <<=
+	SHIFT_RIGHT_ASSIGN          // >>=
+	UNSIGNED_SHIFT_RIGHT_ASSIGN // >>>=
 
 	ASSIGN           // =
 	EQUAL            // ==
@@ -61,12 +75,17 @@ const (
 	LOGICAL_OR       // ||
 
 	VAR        // var
+	LET        // let
+	CONST      // const
 	FUN        // fun
 	RETURN     // return
 	IF         // if
 	ELSE       // else
 	BREAK      // break
 	FOR        // for
+	IN         // in
+	WHILE      // while
+	DO         // do
 	SWITCH     // switch
 	CASE       // case
 	DEFAULT    // default
@@ -106,8 +125,10 @@ var tokenStringMap = [...]string{
 	DOT:               ".",
 	COMMA:             ",",
 	COLON:             ":",
+	QUESTION:          "?",
 	SEMICOLON:         ";",
 	ARROW:             "->",
+	BACKTICK:          "`",
 
 	NUMBER:  "NUMBER",
 	STRING:  "STRING",
@@ -119,8 +140,14 @@ var tokenStringMap = [...]string{
 	MULTIPLY:              "*",
 	DIVIDE:                "/",
 	REMAINDER:             "%",
+	EXPONENT:              "**",
 	AND_ARITHMETIC:        "&",
 	OR_ARITHMETIC:         "|",
+	XOR_ARITHMETIC:        "^",
+	BITWISE_NOT:           "~",
+	SHIFT_LEFT:            "<<",
+	SHIFT_RIGHT:           ">>",
+	UNSIGNED_SHIFT_RIGHT:  ">>>",
 	INCREMENT:             "++",
 	DECREMENT:             "--",
 	ADDITION_ASSIGN:       "+=",
@@ -128,8 +155,14 @@ var tokenStringMap = [...]string{
 	MULTIPLY_ASSIGN:       "*=",
 	DIVIDE_ASSIGN:         "/=",
 	REMAINDER_ASSIGN:      "%=",
+	EXPONENT_ASSIGN:       "**=",
 	AND_ARITHMETIC_ASSIGN: "&=",
 	OR_ARITHMETIC_ASSIGN:  "|=",
+	XOR_ARITHMETIC_ASSIGN: "^=",
+
+	SHIFT_LEFT_ASSIGN:           "<<=",
+	SHIFT_RIGHT_ASSIGN:          ">>=",
+	UNSIGNED_SHIFT_RIGHT_ASSIGN: ">>>=",
 
 	ASSIGN:           "=",
 	EQUAL:            "==",
@@ -143,12 +176,17 @@ var tokenStringMap = [...]string{
 	LOGICAL_OR:       "||",
 
 	VAR:        "var",
+	LET:        "let",
+	CONST:      "const",
 	FUN:        "fun",
 	RETURN:     "return",
 	IF:         "if",
 	ELSE:       "else",
 	BREAK:      "break",
 	FOR:        "for",
+	IN:         "in",
+	WHILE:      "while",
+	DO:         "do",
 	SWITCH:     "switch",
 	CASE:       "case",
 	DEFAULT:    "default",
@@ -175,12 +213,17 @@ var keywordMap = map[string]Token{
 	"false":      BOOLEAN,
 	"null":       NULL,
 	"var":        VAR,
+	"let":        LET,
+	"const":      CONST,
 	"fun":        FUN,
 	"return":     RETURN,
 	"if":         IF,
 	"else":       ELSE,
 	"break":      BREAK,
 	"for":        FOR,
+	"in":         IN,
+	"while":      WHILE,
+	"do":         DO,
 	"switch":     SWITCH,
 	"case":       CASE,
 	"default":    DEFAULT,
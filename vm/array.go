@@ -9,6 +9,8 @@ type ArrayObject struct {
 	BaseObject
 	values ValueArray
 	length uint32
+	// ownProperties is set once valueMapping is no longer the shared arrayProps.
+	ownProperties bool
 }
 
 func (self *ArrayObject) init() {
@@ -24,6 +26,11 @@ func (self *ArrayObject) getValueByIndex(prop IntValue, defaultValue Value) Valu
 }
 
 func (self *ArrayObject) toLiteral() string {
+	if self.inLiteral {
+		return "[Circular]"
+	}
+	self.inLiteral = true
+	defer func() { self.inLiteral = false }()
 	var literals []string
 	for _, value := range self.values {
 		valueFormat := "%s"
@@ -34,3 +41,29 @@ func (self *ArrayObject) toLiteral() string {
 	}
 	return "[" + strings.Join(literals, ",") + "]"
 }
+
+// setProperty gives the array its own copy of the shared array methods before
+// the first property is set.
+func (self *ArrayObject) setProperty(name string, value Value) {
+	if !self.ownProperties {
+		valueMapping := make(map[string]Value, len(self.valueMapping)+1)
+		for key, property := range self.valueMapping {
+			valueMapping[key] = property
+		}
+		self.valueMapping = valueMapping
+		self.ownProperties = true
+	}
+	self.BaseObject.setProperty(name, value)
+}
+
+func (self *ArrayObject) setValueByIndex(index int, value Value) {
+	for len(self.values) < index {
+		self.values = append(self.values, Const_Null_Value)
+	}
+	if index < len(self.values) {
+		self.values[index] = value
+	} else {
+		self.values = append(self.values, value)
+	}
+	self.length = uint32(len(self.values))
+}
@@ -9,11 +9,13 @@ const (
 	BlockSwitch
 	BlockIterator
 	BlockTry
+	BlockLabel
 )
 
 type Block struct {
 	outer        *Block
 	blockType    BlockType
+	label        string
 	breaks       []int
 	continueBase int
 	continues    []int
@@ -1,17 +1,16 @@
 package vm
 
 var arrayProps = map[string]Value{
-	"get": Object{&NativeFunObject{fun: func(call NativeFunCall) Value {
-		this := call.this.toObject().self.(*ArrayObject)
-		args := call.args
-		if len(args) <= 0 {
+	"get": Object{&NativeFunObject{fun: func(call FunctionCall) Value {
+		this := arrayThis(call, "get")
+		if len(call.Arguments) <= 0 {
 			return nil
 		}
-		return this.getValueByIndex(args[0].(IntValue), nil)
+		return this.getValueByIndex(IntValue(arrayIndex(call, "get")), nil)
 	}}},
-	"add": Object{&NativeFunObject{fun: func(call NativeFunCall) Value {
-		this := call.this.toObject().self.(*ArrayObject)
-		args := call.args
+	"add": Object{&NativeFunObject{fun: func(call FunctionCall) Value {
+		this := arrayThis(call, "add")
+		args := call.Arguments
 		if len(args) <= 0 {
 			return nil
 		}
@@ -21,16 +20,42 @@ var arrayProps = map[string]Value{
 		this.length = uint32(this.values.size())
 		return nil
 	}}},
-	"remove": Object{&NativeFunObject{fun: func(call NativeFunCall) Value {
-		this := call.this.toObject().self.(*ArrayObject)
-		args := call.args
-		if len(args) <= 0 {
+	"remove": Object{&NativeFunObject{fun: func(call FunctionCall) Value {
+		this := arrayThis(call, "remove")
+		if len(call.Arguments) <= 0 {
 			return nil
 		}
-		return this.values.remove(int(args[0].toInt()))
+		index := arrayIndex(call, "remove")
+		if index < 0 || index >= int64(this.values.size()) {
+			panic(call.vm.runtime.newRangeError("Index %d is out of range for an array of length %d", index, this.values.size()))
+		}
+		value := this.values.remove(int(index))
+		this.length = uint32(this.values.size())
+		return value
 	}}},
-	"size": Object{&NativeFunObject{fun: func(call NativeFunCall) Value {
-		this := call.this.toObject().self.(*ArrayObject)
+	"size": Object{&NativeFunObject{fun: func(call FunctionCall) Value {
+		this := arrayThis(call, "size")
 		return ToIntValue(int64(this.values.size()))
 	}}},
 }
+
+// arrayThis returns the array an array method is called on, it throws a
+// TypeError for any other receiver.
+func arrayThis(call FunctionCall, method string) *ArrayObject {
+	if call.This != nil && call.This.isObject() {
+		if array, ok := call.This.toObject().self.(*ArrayObject); ok {
+			return array
+		}
+	}
+	panic(call.vm.runtime.newTypeError("Array method %s called on a value that is not an array", method))
+}
+
+// arrayIndex returns the first argument as an index, it throws a TypeError if
+// it is not an int.
+func arrayIndex(call FunctionCall, method string) int64 {
+	index := call.Arguments[0]
+	if !index.isInt() {
+		panic(call.vm.runtime.newTypeError("Array method %s expects an int index, got %s", method, index.toLiteral()))
+	}
+	return index.toInt()
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
+	"github.com/istrangers/demolanguage/parser"
+	"github.com/istrangers/demolanguage/token"
 	"regexp"
 )
 
@@ -32,6 +34,8 @@ type Compiler struct {
 	program *Program
 	scope   *Scope
 	block   *Block
+	// label is the pending label of a labelled loop, claimed by the next openBlockLoop.
+	label string
 
 	classScope *ClassScope
 	evalVM     *VM
@@ -39,6 +43,8 @@ type Compiler struct {
 
 func CreateCompiler() *Compiler {
 	evalVM := CreateVM()
+	// Constant folding must not wrap, an overflowing expression is left to the VM running the program.
+	evalVM.checkedArithmetic = true
 	compiler := &Compiler{
 		program: &Program{},
 		evalVM:  evalVM,
@@ -46,13 +52,33 @@ func CreateCompiler() *Compiler {
 	return compiler
 }
 
+func Compile(fileName string, script string) (program *Program, err error) {
+	parser := parser.CreateParser(1, fileName, script, true, true)
+	in, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			if syntaxError, ok := x.(*CompilerSyntaxError); ok {
+				program, err = nil, syntaxError
+				return
+			}
+			panic(x)
+		}
+	}()
+	compiler := CreateCompiler()
+	compiler.compile(in)
+	return compiler.program, nil
+}
+
 func (self *Compiler) compile(in *ast.Program) {
 	self.program.source = in.File
 
 	scope := self.openScope()
 	scope.isDynamic = true
 	body := in.Body
-	declarationList := in.DeclarationList
+	declarationList := append(in.DeclarationList, self.lexicalDeclarationList(body)...)
 	remainingStatements := self.definingUpgrading(body, declarationList)
 	self.compileStatements(remainingStatements, true)
 
@@ -77,10 +103,24 @@ func (self *Compiler) definingUpgrading(body []ast.Statement, declarationList []
 		}
 	}
 	self.functionUpgrading(funs)
-	varNames := self.compileDeclarationList(declarationList)
+	var varNames, letNames, constNames []string
+	for _, name := range self.compileDeclarationList(declarationList) {
+		if binding := self.scope.getBinding(name); binding.isConst {
+			constNames = append(constNames, name)
+		} else if binding.isLexical {
+			letNames = append(letNames, name)
+		} else {
+			varNames = append(varNames, name)
+		}
+	}
+	if len(body) > 0 {
+		self.program.addSourceMap(int(body[0].StartIndex()) - 1)
+	}
 	self.addProgramInstructions(&BindDefining{
 		funNames,
 		varNames,
+		letNames,
+		constNames,
 	})
 	return
 }
@@ -102,6 +142,9 @@ func (self *Compiler) compileScopeDeclarationList(scope *Scope, declarationList
 			switch t := binding.Target.(type) {
 			case *ast.Identifier:
 				self.checkScopeVarConflict(scope, t.Name, int(t.StartIndex()-1))
+				binding := scope.getBinding(t.Name)
+				binding.isLexical = declaration.Token == token.LET || declaration.Token == token.CONST
+				binding.isConst = declaration.Token == token.CONST
 				varNames = append(varNames, t.Name)
 			}
 		}
@@ -123,7 +166,7 @@ func (self *Compiler) compileScopeDeclared(body []ast.Statement) bool {
 	var declarationList []*ast.VariableDeclaration
 	for _, st := range body {
 		if varSt, ok := st.(*ast.VarStatement); ok {
-			declarationList = append(declarationList, &ast.VariableDeclaration{Var: varSt.Var, List: varSt.List})
+			declarationList = append(declarationList, &ast.VariableDeclaration{Var: varSt.Var, Token: varSt.Token, List: varSt.List})
 			scopeDeclared = true
 		}
 	}
@@ -131,6 +174,30 @@ func (self *Compiler) compileScopeDeclared(body []ast.Statement) bool {
 	return scopeDeclared
 }
 
+// lexicalDeclarationList collects the let and const declarations made directly in
+// body, which the parser does not hoist like var declarations.
+func (self *Compiler) lexicalDeclarationList(body []ast.Statement) []*ast.VariableDeclaration {
+	var declarationList []*ast.VariableDeclaration
+	for _, st := range body {
+		if varSt, ok := st.(*ast.VarStatement); ok && varSt.Token != token.VAR {
+			declarationList = append(declarationList, &ast.VariableDeclaration{Var: varSt.Var, Token: varSt.Token, List: varSt.List})
+		}
+	}
+	return declarationList
+}
+
+// checkConstAssign rejects an assignment to a const binding visible at compile time.
+func (self *Compiler) checkConstAssign(name string, offset int) {
+	for scope := self.scope; scope != nil; scope = scope.outer {
+		if binding := scope.getBinding(name); binding != nil {
+			if binding.isConst {
+				self.throwSyntaxError(offset, "Assignment to constant variable '%s'", name)
+			}
+			return
+		}
+	}
+}
+
 func (self *Compiler) addProgramValue(value Value) int {
 	return self.program.addValue(value)
 }
@@ -173,7 +240,9 @@ func (self *Compiler) openBlockScope() *Block {
 }
 
 func (self *Compiler) openBlockLoop() *Block {
-	return self.openBlock(BlockLoop)
+	block := self.openBlock(BlockLoop)
+	block.label, self.label = self.label, ""
+	return block
 }
 
 func (self *Compiler) openBlockSwitch() *Block {
@@ -204,7 +273,7 @@ func (self *Compiler) closeBlock() {
 }
 
 func (self *Compiler) findBlockByType(blockTypes []BlockType, isBreak bool) *Block {
-	for block := self.block; block != nil; block = self.block.outer {
+	for block := self.block; block != nil; block = block.outer {
 		for _, blockType := range blockTypes {
 			if block.blockType == blockType && (blockType != BlockSwitch || isBreak) {
 				return block
@@ -214,6 +283,15 @@ func (self *Compiler) findBlockByType(blockTypes []BlockType, isBreak bool) *Blo
 	return nil
 }
 
+func (self *Compiler) findBlockByLabel(label string) *Block {
+	for block := self.block; block != nil; block = block.outer {
+		if block.label == label {
+			return block
+		}
+	}
+	return nil
+}
+
 func (self *Compiler) updateEnterBlock(enterBlock *EnterBlock) {
 	stackSize, stashSize := 0, 0
 	for _, b := range self.scope.bindings {
@@ -277,7 +355,6 @@ func (self *Compiler) throwSyntaxError(offset int, format string, args ...any) C
 			Message: fmt.Sprintf(format, args...),
 		},
 	})
-	return nil
 }
 
 func (self *Compiler) checkVarConflict(name string, pos int) {
@@ -4,6 +4,7 @@ import (
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/file"
 	"github.com/istrangers/demolanguage/token"
+	"math/big"
 )
 
 type CompiledExpression interface {
@@ -41,6 +42,31 @@ func (self CompiledLiteralExpression) isConstExpression() bool {
 	return true
 }
 
+type CompiledTemplateLiteralExpression struct {
+	CompiledBaseExpression
+	elements    []*ast.TemplateElement
+	expressions []CompiledExpression
+}
+
+func (self CompiledTemplateLiteralExpression) isConstExpression() bool {
+	for _, expression := range self.expressions {
+		if !expression.isConstExpression() {
+			return false
+		}
+	}
+	return true
+}
+
+// CompiledTemplateObjectExpression is the strings argument passed to the tag of a tagged template.
+type CompiledTemplateObjectExpression struct {
+	CompiledBaseExpression
+	elements []*ast.TemplateElement
+}
+
+func (self CompiledTemplateObjectExpression) isConstExpression() bool {
+	return false
+}
+
 type CompiledObjectLiteralExpression struct {
 	CompiledBaseExpression
 	properties []ast.Property
@@ -112,6 +138,26 @@ func (self CompiledBinaryExpression) isConstExpression() bool {
 	return self.left.isConstExpression() && self.right.isConstExpression()
 }
 
+type CompiledConditionalExpression struct {
+	CompiledBaseExpression
+	test       CompiledExpression
+	consequent CompiledExpression
+	alternate  CompiledExpression
+}
+
+func (self CompiledConditionalExpression) isConstExpression() bool {
+	if !self.test.isConstExpression() {
+		return false
+	}
+	if v, ex := self.compile.evalConstExpr(self.test); ex == nil {
+		if v.toBool() {
+			return self.consequent.isConstExpression()
+		}
+		return self.alternate.isConstExpression()
+	}
+	return true
+}
+
 type CompiledAssignExpression struct {
 	CompiledBaseExpression
 	left     CompiledExpression
@@ -202,6 +248,8 @@ func (self *Compiler) compileExpression(expression ast.Expression) CompiledExpre
 		return self.compileNumberLiteral(expr)
 	case *ast.StringLiteral:
 		return self.compileStringLiteral(expr)
+	case *ast.TemplateLiteral:
+		return self.compileTemplateLiteral(expr)
 	case *ast.BooleanLiteral:
 		return self.compileBooleanLiteral(expr)
 	case *ast.ObjectLiteral:
@@ -214,6 +262,8 @@ func (self *Compiler) compileExpression(expression ast.Expression) CompiledExpre
 		return self.compileThisExpression(expr)
 	case *ast.UnaryExpression:
 		return self.compileUnaryExpression(expr)
+	case *ast.ConditionalExpression:
+		return self.compileConditionalExpression(expr)
 	case *ast.BinaryExpression:
 		return self.compileBinaryExpression(expr)
 	case *ast.AssignExpression:
@@ -251,6 +301,8 @@ func (self *Compiler) compileNumberLiteral(expr *ast.NumberLiteral) CompiledExpr
 		value = ToIntValue(val)
 	case float64:
 		value = ToFloatValue(val)
+	case *big.Int:
+		value = ToBigIntValue(val)
 	default:
 		return self.throwSyntaxError(int(expr.StartIndex())-1, "Unsupported number literal type: %T", expr.Value)
 	}
@@ -267,6 +319,26 @@ func (self *Compiler) compileStringLiteral(expr *ast.StringLiteral) CompiledExpr
 	}
 }
 
+func (self *Compiler) compileTemplateLiteral(expr *ast.TemplateLiteral) CompiledExpression {
+	expressions := self.compileCallArguments(expr.Expressions)
+	if expr.Tag == nil {
+		return &CompiledTemplateLiteralExpression{
+			self.createCompiledBaseExpression(expr.StartIndex()),
+			expr.Elements,
+			expressions,
+		}
+	}
+	templateObject := &CompiledTemplateObjectExpression{
+		self.createCompiledBaseExpression(expr.OpenQuote),
+		expr.Elements,
+	}
+	return &CompiledCallExpression{
+		self.createCompiledBaseExpression(expr.StartIndex()),
+		self.compileExpression(expr.Tag),
+		append([]CompiledExpression{templateObject}, expressions...),
+	}
+}
+
 func (self *Compiler) compileBooleanLiteral(expr *ast.BooleanLiteral) CompiledExpression {
 	return &CompiledLiteralExpression{
 		self.createCompiledBaseExpression(expr.StartIndex()),
@@ -310,6 +382,15 @@ func (self *Compiler) compileUnaryExpression(expr *ast.UnaryExpression) Compiled
 	}
 }
 
+func (self *Compiler) compileConditionalExpression(expr *ast.ConditionalExpression) CompiledExpression {
+	return &CompiledConditionalExpression{
+		self.createCompiledBaseExpression(expr.StartIndex()),
+		self.compileExpression(expr.Test),
+		self.compileExpression(expr.Consequent),
+		self.compileExpression(expr.Alternate),
+	}
+}
+
 func (self *Compiler) compileBinaryExpression(expr *ast.BinaryExpression) CompiledExpression {
 	return &CompiledBinaryExpression{
 		self.createCompiledBaseExpression(expr.StartIndex()),
@@ -3,6 +3,7 @@ package vm
 import (
 	"github.com/istrangers/demolanguage/ast"
 	"github.com/istrangers/demolanguage/token"
+	"strings"
 )
 
 func (self *Compiler) evalConstExpr(expr CompiledExpression) (Value, *Exception) {
@@ -37,13 +38,14 @@ func (self *Compiler) evalConstExpr(expr CompiledExpression) (Value, *Exception)
 	return evalVM.pop(), nil
 }
 
-func (self *Compiler) evalConstValueExpr(expr CompiledExpression) Value {
-	value, ex := self.evalConstExpr(expr)
-	if ex != nil {
-		expr.addSourceMap()
-		self.emitThrow(ex.value)
+// constValue folds expr when it is a constant expression that evaluates without
+// throwing, ok is false otherwise.
+func (self *Compiler) constValue(expr CompiledExpression) (value Value, ok bool) {
+	if !expr.isConstExpression() {
+		return nil, false
 	}
-	return value
+	value, ex := self.evalConstExpr(expr)
+	return value, ex == nil
 }
 
 func (self *Compiler) emitLoadValue(value Value, putOnStack bool) {
@@ -72,14 +74,12 @@ func (self *Compiler) emitVarAssign(name string, pos int, expr CompiledExpressio
 	}
 }
 
-func (self *Compiler) emitThrow(value Value) {
-
-}
-
+// handlingConstExpression folds expr into its value, an expression whose evaluation
+// throws is compiled as is so that the error is raised when the program runs.
 func (self *Compiler) handlingConstExpression(expr CompiledExpression, putOnStack bool) {
 	value, ex := self.evalConstExpr(expr)
 	if ex != nil {
-		self.emitThrow(ex.value)
+		self.handlingGetterExpression(expr, putOnStack)
 	} else {
 		self.emitLoadValue(value, putOnStack)
 	}
@@ -89,6 +89,10 @@ func (self *Compiler) handlingGetterExpression(expr CompiledExpression, putOnSta
 	switch expr := expr.(type) {
 	case *CompiledLiteralExpression:
 		self.handlingGetterCompiledLiteralExpression(expr, putOnStack)
+	case *CompiledTemplateLiteralExpression:
+		self.handlingGetterCompiledTemplateLiteralExpression(expr, putOnStack)
+	case *CompiledTemplateObjectExpression:
+		self.handlingGetterCompiledTemplateObjectExpression(expr, putOnStack)
 	case *CompiledObjectLiteralExpression:
 		self.handlingGetterCompiledObjectLiteralExpression(expr, putOnStack)
 	case *CompiledArrayLiteralExpression:
@@ -99,6 +103,8 @@ func (self *Compiler) handlingGetterExpression(expr CompiledExpression, putOnSta
 		self.handlingGetterCompiledThisExpression(expr, putOnStack)
 	case *CompiledUnaryExpression:
 		self.handlingGetterCompiledUnaryExpression(expr, putOnStack)
+	case *CompiledConditionalExpression:
+		self.handlingGetterCompiledConditionalExpression(expr, putOnStack)
 	case *CompiledBinaryExpression:
 		self.handlingGetterCompiledBinaryExpression(expr, putOnStack)
 	case *CompiledAssignExpression:
@@ -130,6 +136,44 @@ func (self *Compiler) handlingGetterCompiledLiteralExpression(expr *CompiledLite
 	self.emitLoadValue(expr.value, putOnStack)
 }
 
+func (self *Compiler) handlingGetterCompiledTemplateLiteralExpression(expr *CompiledTemplateLiteralExpression, putOnStack bool) {
+	count := 0
+	for i, element := range expr.elements {
+		if element.Parsed != "" {
+			self.emitLoadValue(ToStringValue(element.Parsed), true)
+			count++
+		}
+		if i < len(expr.expressions) {
+			self.chooseHandlingGetterExpression(expr.expressions[i], true)
+			count++
+		}
+	}
+	expr.addSourceMap()
+	if count == 0 {
+		self.emitLoadValue(Const_Empty_String_Value, true)
+		count++
+	}
+	self.addProgramInstructions(Concat(count))
+
+	if !putOnStack {
+		self.addProgramInstructions(Pop)
+	}
+}
+
+func (self *Compiler) handlingGetterCompiledTemplateObjectExpression(expr *CompiledTemplateObjectExpression, putOnStack bool) {
+	var cooked, raw []string
+	lineTerminators := strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	for _, element := range expr.elements {
+		cooked = append(cooked, element.Parsed)
+		raw = append(raw, lineTerminators.Replace(element.Literal))
+	}
+	self.addProgramInstructions(NewTemplateObject{cooked, raw})
+
+	if !putOnStack {
+		self.addProgramInstructions(Pop)
+	}
+}
+
 func (self *Compiler) handlingGetterCompiledObjectLiteralExpression(expr *CompiledObjectLiteralExpression, putOnStack bool) {
 	expr.addSourceMap()
 
@@ -176,6 +220,9 @@ func (self *Compiler) handlingGetterCompiledIdentifierExpression(expr *CompiledI
 	if exists {
 		binding.markAccessPoint(self.scope)
 		self.addProgramInstructions(LoadStackVar(0))
+		if binding.isLexical {
+			self.addProgramInstructions(CheckInitialized(expr.name))
+		}
 	} else {
 		self.addProgramInstructions(LoadVar(expr.name))
 	}
@@ -206,6 +253,9 @@ func (self *Compiler) handlingGetterCompiledUnaryExpression(expr *CompiledUnaryE
 	case token.NOT:
 		self.chooseHandlingGetterExpression(expr.operand, true)
 		self.addProgramInstructions(Not)
+	case token.BITWISE_NOT:
+		self.chooseHandlingGetterExpression(expr.operand, true)
+		self.addProgramInstructions(BitNot)
 	case token.SUBTRACT:
 		self.chooseHandlingGetterExpression(expr.operand, true)
 		self.addProgramInstructions(Neg)
@@ -226,10 +276,35 @@ func (self *Compiler) handlingGetterCompiledUnaryExpression(expr *CompiledUnaryE
 	}
 }
 
+func (self *Compiler) handlingGetterCompiledConditionalExpression(expr *CompiledConditionalExpression, putOnStack bool) {
+	if expr.test.isConstExpression() {
+		if v, ex := self.evalConstExpr(expr.test); ex == nil {
+			if v.toBool() {
+				self.chooseHandlingGetterExpression(expr.consequent, putOnStack)
+			} else {
+				self.chooseHandlingGetterExpression(expr.alternate, putOnStack)
+			}
+			return
+		}
+	}
+
+	self.handlingGetterExpression(expr.test, true)
+	expr.addSourceMap()
+	alternateJmp := self.getInstructionSize()
+	self.addProgramInstructions(nil)
+	self.chooseHandlingGetterExpression(expr.consequent, putOnStack)
+	consequentJmp := self.getInstructionSize()
+	self.addProgramInstructions(nil)
+	self.setProgramInstruction(alternateJmp, Jne(self.getInstructionSize()-alternateJmp))
+	self.chooseHandlingGetterExpression(expr.alternate, putOnStack)
+	self.setProgramInstruction(consequentJmp, Jump(self.getInstructionSize()-consequentJmp))
+}
+
 func (self *Compiler) handlingGetterCompiledBinaryExpression(expr *CompiledBinaryExpression, putOnStack bool) {
 	operator := expr.operator
 	if operator == token.LOGICAL_OR || operator == token.LOGICAL_AND {
 
+		folded := false
 		if expr.left.isConstExpression() {
 			if v, ex := self.evalConstExpr(expr.left); ex == nil {
 				boolVal := v.toBool()
@@ -239,10 +314,10 @@ func (self *Compiler) handlingGetterCompiledBinaryExpression(expr *CompiledBinar
 				} else {
 					self.chooseHandlingGetterExpression(expr.right, putOnStack)
 				}
-			} else {
-				self.emitThrow(ex.value)
+				folded = true
 			}
-		} else {
+		}
+		if !folded {
 			self.handlingGetterExpression(expr.left, true)
 			expr.addSourceMap()
 			index := self.getInstructionSize()
@@ -275,6 +350,20 @@ func (self *Compiler) handlingGetterCompiledBinaryExpression(expr *CompiledBinar
 			self.addProgramInstructions(Div)
 		case token.REMAINDER:
 			self.addProgramInstructions(Mod)
+		case token.EXPONENT:
+			self.addProgramInstructions(Pow)
+		case token.AND_ARITHMETIC:
+			self.addProgramInstructions(AND)
+		case token.OR_ARITHMETIC:
+			self.addProgramInstructions(OR)
+		case token.XOR_ARITHMETIC:
+			self.addProgramInstructions(XOR)
+		case token.SHIFT_LEFT:
+			self.addProgramInstructions(SHL)
+		case token.SHIFT_RIGHT:
+			self.addProgramInstructions(SHR)
+		case token.UNSIGNED_SHIFT_RIGHT:
+			self.addProgramInstructions(USHR)
 		case token.EQUAL:
 			self.addProgramInstructions(EQ)
 		case token.NOT_EQUAL:
@@ -327,6 +416,11 @@ func (self *Compiler) handlingGetterCompiledAssignExpression(expr *CompiledAssig
 			self.handlingGetterExpression(expr.right, true)
 			self.addProgramInstructions(Mod)
 		}, false, putOnStack)
+	case token.EXPONENT:
+		self.handlingUnaryExpression(expr.left, func() {
+			self.handlingGetterExpression(expr.right, true)
+			self.addProgramInstructions(Pow)
+		}, false, putOnStack)
 	case token.AND_ARITHMETIC:
 		self.handlingUnaryExpression(expr.left, func() {
 			self.handlingGetterExpression(expr.right, true)
@@ -337,9 +431,33 @@ func (self *Compiler) handlingGetterCompiledAssignExpression(expr *CompiledAssig
 			self.handlingGetterExpression(expr.right, true)
 			self.addProgramInstructions(OR)
 		}, false, putOnStack)
+	case token.XOR_ARITHMETIC:
+		self.handlingUnaryExpression(expr.left, func() {
+			self.handlingGetterExpression(expr.right, true)
+			self.addProgramInstructions(XOR)
+		}, false, putOnStack)
+	case token.SHIFT_LEFT:
+		self.handlingUnaryExpression(expr.left, func() {
+			self.handlingGetterExpression(expr.right, true)
+			self.addProgramInstructions(SHL)
+		}, false, putOnStack)
+	case token.SHIFT_RIGHT:
+		self.handlingUnaryExpression(expr.left, func() {
+			self.handlingGetterExpression(expr.right, true)
+			self.addProgramInstructions(SHR)
+		}, false, putOnStack)
+	case token.UNSIGNED_SHIFT_RIGHT:
+		self.handlingUnaryExpression(expr.left, func() {
+			self.handlingGetterExpression(expr.right, true)
+			self.addProgramInstructions(USHR)
+		}, false, putOnStack)
 	default:
 		self.throwSyntaxError(expr.offset, "Unknown assign operator: %s", expr.operator.String())
 	}
+
+	if expr.operator != token.ASSIGN && !putOnStack {
+		self.addProgramInstructions(Pop)
+	}
 }
 
 func (self *Compiler) handlingGetterCompiledFunLiteralExpression(expr *CompiledFunLiteralExpression, putOnStack bool) {
@@ -353,7 +471,9 @@ func (self *Compiler) handlingGetterCompiledFunLiteralExpression(expr *CompiledF
 }
 
 func (self *Compiler) compileFunProgram(expr *CompiledFunLiteralExpression) (*Program, int) {
-	originProgram := self.program
+	// Break and continue never leave a function, its blocks start over.
+	originProgram, originBlock := self.program, self.block
+	self.block = nil
 	self.program = &Program{
 		source:       originProgram.source,
 		instructions: InstructionArray{},
@@ -399,16 +519,17 @@ func (self *Compiler) compileFunProgram(expr *CompiledFunLiteralExpression) (*Pr
 
 	self.scope.bindName(thisBindingName)
 
+	body := expr.body.Body
+	declarationList := append(expr.declarationList, self.lexicalDeclarationList(body)...)
 	var enterFunBodyIndex int
 	if hasInit {
 		self.openScopeNested()
 		enterFunBodyIndex = self.getInstructionSize()
 		self.addProgramInstructions(nil)
-		self.compileScopeDeclarationList(funcScope, expr.declarationList)
+		self.compileScopeDeclarationList(funcScope, declarationList)
 	}
 
-	self.compileDeclarationList(expr.declarationList)
-	body := expr.body.Body
+	self.compileDeclarationList(declarationList)
 	self.compileStatements(body, false)
 	lastStatementIndex := len(body) - 1
 	var lastStatement ast.Statement
@@ -435,7 +556,7 @@ func (self *Compiler) compileFunProgram(expr *CompiledFunLiteralExpression) (*Pr
 
 	funProgram := self.program
 	self.closeScope()
-	self.program = originProgram
+	self.program, self.block = originProgram, originBlock
 
 	return funProgram, len(expr.parameterList.List)
 }
@@ -446,8 +567,12 @@ func (self *Compiler) handlingGetterCompiledCallExpression(expr *CompiledCallExp
 		callee.addSourceMap()
 		binding, exists := self.scope.lookupName(callee.name)
 		if exists {
+			self.addProgramInstructions(LoadNull)
 			binding.markAccessPoint(self.scope)
 			self.addProgramInstructions(LoadStackVar(0))
+			if binding.isLexical {
+				self.addProgramInstructions(CheckInitialized(callee.name))
+			}
 		} else {
 			self.addProgramInstructions(LoadDynamicCallee(callee.name))
 		}
@@ -467,6 +592,7 @@ func (self *Compiler) handlingGetterCompiledCallExpression(expr *CompiledCallExp
 		self.chooseHandlingGetterExpression(argument, true)
 	}
 
+	expr.addSourceMap()
 	if isNewCall {
 		self.addProgramInstructions(New(len(expr.arguments)))
 	} else {
@@ -601,18 +727,29 @@ func (self *Compiler) handlingSetterExpression(expr CompiledExpression, valueExp
 		self.handlingSetterCompiledIdentifierExpression(expr, valueExpr, putOnStack)
 	case *CompiledDotExpression:
 		self.handlingSetterCompiledDotExpression(expr, valueExpr, putOnStack)
+	case *CompiledBracketExpression:
+		self.handlingSetterCompiledBracketExpression(expr, valueExpr, putOnStack)
 	}
 }
 
 func (self *Compiler) handlingSetterCompiledIdentifierExpression(expr *CompiledIdentifierExpression, valueExpr CompiledExpression, putOnStack bool) {
-	self.addProgramInstructions(ResolveVar(expr.name))
-	self.chooseHandlingGetterExpression(valueExpr, true)
+	self.checkConstAssign(expr.name, expr.offset)
 	binding, exists := self.scope.lookupName(expr.name)
 	if exists {
+		self.chooseHandlingGetterExpression(valueExpr, true)
+		if putOnStack {
+			self.addProgramInstructions(Dup)
+		}
 		binding.markAccessPoint(self.scope)
 		self.addProgramInstructions(PutStackVar(0))
 	} else {
+		self.addProgramInstructions(ResolveVar(expr.name))
+		self.chooseHandlingGetterExpression(valueExpr, true)
+		self.program.addSourceMap(expr.offset)
 		self.addProgramInstructions(PutVar(0))
+		if !putOnStack {
+			self.addProgramInstructions(Pop)
+		}
 	}
 }
 
@@ -620,7 +757,19 @@ func (self *Compiler) handlingSetterCompiledDotExpression(expr *CompiledDotExpre
 	self.handlingGetterExpression(expr.left, true)
 	self.handlingGetterExpression(valueExpr, true)
 	expr.addSourceMap()
-	self.addProgramInstructions(AddProp(expr.name))
+	self.addProgramInstructions(SetProp(expr.name))
+
+	if !putOnStack {
+		self.addProgramInstructions(Pop)
+	}
+}
+
+func (self *Compiler) handlingSetterCompiledBracketExpression(expr *CompiledBracketExpression, valueExpr CompiledExpression, putOnStack bool) {
+	self.handlingGetterExpression(expr.left, true)
+	self.handlingGetterExpression(expr.indexOrName, true)
+	self.handlingGetterExpression(valueExpr, true)
+	expr.addSourceMap()
+	self.addProgramInstructions(SetElem)
 
 	if !putOnStack {
 		self.addProgramInstructions(Pop)
@@ -631,20 +780,73 @@ func (self *Compiler) handlingUnaryExpression(expr CompiledExpression, instructi
 	switch expr := expr.(type) {
 	case *CompiledIdentifierExpression:
 		self.handlingUnaryCompiledIdentifierExpression(expr, instructionBody, postfix, putOnStack)
+	case *CompiledDotExpression:
+		self.handlingUnaryCompiledDotExpression(expr, instructionBody, postfix)
+	case *CompiledBracketExpression:
+		self.handlingUnaryCompiledBracketExpression(expr, instructionBody, postfix)
+	}
+}
+
+// handlingUnaryCompiledDotExpression evaluates the object once, then reads the
+// property, applies the update and writes it back.
+func (self *Compiler) handlingUnaryCompiledDotExpression(expr *CompiledDotExpression, instructionBody func(), postfix bool) {
+	self.handlingGetterExpression(expr.left, true)
+	expr.addSourceMap()
+	self.addProgramInstructions(Dup, GetProp(expr.name))
+	if postfix {
+		self.addProgramInstructions(DupUnder(1))
+	}
+	instructionBody()
+	expr.addSourceMap()
+	self.addProgramInstructions(SetProp(expr.name))
+	if postfix {
+		self.addProgramInstructions(Pop)
+	}
+}
+
+// handlingUnaryCompiledBracketExpression evaluates the object and the key once,
+// then reads the element, applies the update and writes it back.
+func (self *Compiler) handlingUnaryCompiledBracketExpression(expr *CompiledBracketExpression, instructionBody func(), postfix bool) {
+	self.handlingGetterExpression(expr.left, true)
+	self.handlingGetterExpression(expr.indexOrName, true)
+	expr.addSourceMap()
+	self.addProgramInstructions(DupN(2), GetPropOrElem)
+	if postfix {
+		self.addProgramInstructions(DupUnder(2))
+	}
+	instructionBody()
+	expr.addSourceMap()
+	self.addProgramInstructions(SetElem)
+	if postfix {
+		self.addProgramInstructions(Pop)
 	}
 }
 
 func (self *Compiler) handlingUnaryCompiledIdentifierExpression(expr *CompiledIdentifierExpression, instructionBody func(), postfix bool, putOnStack bool) {
+	self.checkConstAssign(expr.name, expr.offset)
 	binding, exists := self.scope.lookupName(expr.name)
 	if exists {
 		self.chooseHandlingGetterExpression(expr, true)
+		if postfix {
+			self.addProgramInstructions(Dup)
+		}
 		instructionBody()
+		if !postfix {
+			self.addProgramInstructions(Dup)
+		}
 		binding.markAccessPoint(self.scope)
 		self.addProgramInstructions(PutStackVar(0))
 	} else {
 		self.addProgramInstructions(ResolveVar(expr.name))
 		self.chooseHandlingGetterExpression(expr, true)
+		if postfix {
+			self.addProgramInstructions(Dup)
+		}
 		instructionBody()
+		self.program.addSourceMap(expr.offset)
 		self.addProgramInstructions(PutVar(0))
+		if postfix {
+			self.addProgramInstructions(Pop)
+		}
 	}
 }
@@ -75,6 +75,8 @@ func (self *Compiler) compileStatement(statement ast.Statement, needResult bool)
 		self.compileVarStatement(st)
 	case ast.BranchStatement:
 		self.compileBranchStatement(st)
+	case *ast.LabelledStatement:
+		self.compileLabelledStatement(st, needResult)
 	case *ast.ReturnStatement:
 		self.compileReturnStatement(st)
 	case *ast.IfStatement:
@@ -83,6 +85,14 @@ func (self *Compiler) compileStatement(statement ast.Statement, needResult bool)
 		self.compileSwitchStatement(st, needResult)
 	case *ast.ForStatement:
 		self.compileForStatement(st, needResult)
+	case *ast.ForInStatement:
+		self.compileForInStatement(st, needResult)
+	case *ast.ForOfStatement:
+		self.compileForOfStatement(st, needResult)
+	case *ast.WhileStatement:
+		self.compileWhileStatement(st, needResult)
+	case *ast.DoWhileStatement:
+		self.compileDoWhileStatement(st, needResult)
 	case *ast.ThrowStatement:
 		self.compileThrowStatement(st)
 	case *ast.TryCatchFinallyStatement:
@@ -126,7 +136,11 @@ func (self *Compiler) compileVarStatement(st *ast.VarStatement) {
 	for _, binding := range st.List {
 		switch target := binding.Target.(type) {
 		case *ast.Identifier:
-			self.emitVarAssign(target.Name, int(target.StartIndex()-1), self.compileExpression(binding.Initializer))
+			initializer := binding.Initializer
+			if initializer == nil && st.Token == token.LET {
+				initializer = &ast.NullLiteral{Index: target.StartIndex()}
+			}
+			self.emitVarAssign(target.Name, int(target.StartIndex()-1), self.compileExpression(initializer))
 		default:
 			self.throwSyntaxError(int(target.StartIndex()-1), "unsupported variable binding target: %T", target)
 		}
@@ -134,17 +148,79 @@ func (self *Compiler) compileVarStatement(st *ast.VarStatement) {
 }
 
 func (self *Compiler) compileBreakStatement(st *ast.BreakStatement) {
+	var block *Block
+	if st.Label != nil {
+		block = self.findLabelledBlock(st.Label)
+	} else {
+		block = self.findBlockByType([]BlockType{BlockLoop, BlockSwitch}, true)
+		if block == nil {
+			self.throwSyntaxError(int(st.StartIndex())-1, "Illegal break statement")
+		}
+	}
+	self.leaveBlocksUntil(block, false)
 	index := self.getInstructionSize()
 	self.addProgramInstructions(nil)
-	block := self.findBlockByType([]BlockType{BlockLoop, BlockSwitch}, true)
-	block.breaks = append(self.block.breaks, index)
+	block.breaks = append(block.breaks, index)
 }
 
 func (self *Compiler) compileContinueStatement(st *ast.ContinueStatement) {
+	var block *Block
+	if st.Label != nil {
+		block = self.findLabelledBlock(st.Label)
+		if block.blockType != BlockLoop {
+			self.throwSyntaxError(int(st.Label.StartIndex())-1, "Illegal continue statement: '%s' does not denote an iteration statement", st.Label.Name)
+		}
+	} else {
+		block = self.findBlockByType([]BlockType{BlockLoop}, false)
+		if block == nil {
+			self.throwSyntaxError(int(st.StartIndex())-1, "Illegal continue statement")
+		}
+	}
+	self.leaveBlocksUntil(block, true)
 	index := self.getInstructionSize()
 	self.addProgramInstructions(nil)
-	block := self.findBlockByType([]BlockType{BlockLoop}, false)
-	block.continues = append(self.block.continues, index)
+	block.continues = append(block.continues, index)
+}
+
+func (self *Compiler) findLabelledBlock(label *ast.Identifier) *Block {
+	block := self.findBlockByLabel(label.Name)
+	if block == nil {
+		self.throwSyntaxError(int(label.StartIndex())-1, "Undefined label '%s'", label.Name)
+	}
+	return block
+}
+
+// compileLabelledStatement labels the loop block of a labelled loop, any other
+// statement is wrapped in a label block that only a labelled break can leave.
+func (self *Compiler) compileLabelledStatement(st *ast.LabelledStatement, needResult bool) {
+	switch st.Statement.(type) {
+	case *ast.ForStatement, *ast.ForInStatement, *ast.ForOfStatement, *ast.WhileStatement, *ast.DoWhileStatement:
+		self.label = st.Label.Name
+		self.compileStatement(st.Statement, needResult)
+	default:
+		self.openBlock(BlockLabel).label = st.Label.Name
+		self.compileStatement(st.Statement, needResult)
+		self.closeBlock()
+	}
+}
+
+// leaveBlocksUntil reserves a LeaveBlock slot for every scope block between the
+// current block and the target, so that jumping out of them unwinds the stack,
+// and leaves every try block on the way, running its finally body.
+// A continue stays inside the iterator scope of the loop it targets.
+func (self *Compiler) leaveBlocksUntil(target *Block, isContinue bool) {
+	for block := self.block; block != nil && block != target; block = block.outer {
+		switch block.blockType {
+		case BlockTry:
+			self.addProgramInstructions(LeaveTry{})
+		case BlockScope, BlockIterator:
+			if isContinue && block.blockType == BlockIterator && block.outer == target {
+				continue
+			}
+			block.breaks = append(block.breaks, self.getInstructionSize())
+			self.addProgramInstructions(nil)
+		}
+	}
 }
 
 func (self *Compiler) compileBranchStatement(st ast.BranchStatement) {
@@ -168,8 +244,7 @@ func (self *Compiler) compileReturnStatement(st *ast.ReturnStatement) {
 
 func (self *Compiler) compileIfStatement(st *ast.IfStatement, needResult bool) {
 	conditionExpr := self.compileExpression(st.Condition)
-	if conditionExpr.isConstExpression() {
-		conditionValue := self.evalConstValueExpr(conditionExpr)
+	if conditionValue, ok := self.constValue(conditionExpr); ok {
 		if conditionValue.toBool() {
 			self.compileStatement(st.Consequent, needResult)
 			self.checkStatementSyntax(st.Alternate)
@@ -196,8 +271,7 @@ func (self *Compiler) compileIfStatement(st *ast.IfStatement, needResult bool) {
 
 func (self *Compiler) compileSwitchStatement(st *ast.SwitchStatement, needResult bool) {
 	discriminantExpr := self.compileExpression(st.Discriminant)
-	if discriminantExpr.isConstExpression() {
-		discriminantValue := self.evalConstValueExpr(discriminantExpr)
+	if discriminantValue, ok := self.constValue(discriminantExpr); ok {
 		var consequent ast.Statement
 		for index, caseStatement := range st.Body {
 			if index == st.Default && consequent == nil {
@@ -208,7 +282,11 @@ func (self *Compiler) compileSwitchStatement(st *ast.SwitchStatement, needResult
 					self.throwSyntaxError(int(caseStatement.StartIndex()-1), "Expression is not a constant")
 					return
 				}
-				caseValue := self.evalConstValueExpr(conditionExpr)
+				caseValue, ex := self.evalConstExpr(conditionExpr)
+				if ex != nil {
+					self.throwSyntaxError(int(caseStatement.StartIndex()-1), "Expression is not a constant")
+					return
+				}
 				if discriminantValue.sameAs(caseValue) {
 					consequent = caseStatement.Consequent
 				}
@@ -270,9 +348,21 @@ func (self *Compiler) compileForStatement(st *ast.ForStatement, needResult bool)
 		}
 		self.compileStatement(st.Body, needResult)
 		blockLoop.continueBase = self.getInstructionSize()
+		copyStashIndex := -1
+		if initializer, ok := st.Initializer.(*ast.VarStatement); ok && initializer.Token == token.LET {
+			copyStashIndex = self.getInstructionSize()
+			self.addProgramInstructions(nil)
+		}
 		if st.Update != nil {
 			updateExpr := self.compileExpression(st.Update)
-			self.handlingGetterExpression(updateExpr, true)
+			self.chooseHandlingGetterExpression(updateExpr, false)
+		}
+		if copyStashIndex != -1 {
+			if self.scope.needStash {
+				self.setProgramInstruction(copyStashIndex, CopyStash)
+			} else {
+				self.setProgramInstruction(copyStashIndex, Jump(1))
+			}
 		}
 		self.addProgramInstructions(Jump(jumpIndex - self.getInstructionSize()))
 		if conditionJumpIndex != -1 {
@@ -283,10 +373,106 @@ func (self *Compiler) compileForStatement(st *ast.ForStatement, needResult bool)
 	self.closeBlock()
 }
 
+func (self *Compiler) compileForInStatement(st *ast.ForInStatement, needResult bool) {
+	self.compileIterationStatement(st.Token, st.Source, st.Key, st.Value, st.Body, needResult)
+}
+
+func (self *Compiler) compileForOfStatement(st *ast.ForOfStatement, needResult bool) {
+	self.compileIterationStatement(st.Token, st.Source, nil, st.Value, st.Body, needResult)
+}
+
+// compileIterationStatement keeps the iterator in a hidden binding of the loop
+// scope and enters a fresh block scope for every entry, so closures created in
+// the body capture the values of their own iteration.
+func (self *Compiler) compileIterationStatement(tkn token.Token, source ast.Expression, key, value *ast.Identifier, body ast.Statement, needResult bool) {
+	blockLoop := self.openBlockLoop()
+
+	self.openScopeNested()
+	self.openBlock(BlockIterator)
+	iteratorBinding, _ := self.scope.bindName(iteratorBindingName)
+	enterIterator := &EnterBlock{}
+	self.addProgramInstructions(enterIterator)
+	self.chooseHandlingGetterExpression(self.compileExpression(source), true)
+	self.program.addSourceMap(int(source.StartIndex()) - 1)
+	if key != nil {
+		self.addProgramInstructions(Enumerate)
+	} else {
+		self.addProgramInstructions(Iterate)
+	}
+	iteratorBinding.markAccessPoint(self.scope)
+	self.addProgramInstructions(InitStackVar(0))
+
+	jumpIndex := self.getInstructionSize()
+	blockLoop.continueBase = jumpIndex
+	iteratorBinding.markAccessPoint(self.scope)
+	self.addProgramInstructions(LoadStackVar(0), IterNext)
+	exitJumpIndex := self.getInstructionSize()
+	self.addProgramInstructions(nil)
+
+	self.openScopeNested()
+	self.openBlock(BlockScope)
+	enter := &EnterBlock{}
+	self.addProgramInstructions(enter)
+	for _, entry := range []struct {
+		identifier *ast.Identifier
+		load       Instruction
+	}{{key, IterKey}, {value, IterValue}} {
+		if entry.identifier == nil {
+			continue
+		}
+		self.checkScopeVarConflict(self.scope, entry.identifier.Name, int(entry.identifier.StartIndex())-1)
+		binding := self.scope.getBinding(entry.identifier.Name)
+		binding.isLexical, binding.isConst = tkn != token.VAR, tkn == token.CONST
+		iteratorBinding.markAccessPoint(self.scope)
+		self.addProgramInstructions(LoadStackVar(0), entry.load)
+		binding.markAccessPoint(self.scope)
+		self.addProgramInstructions(InitStackVar(0))
+	}
+	self.compileStatement(body, needResult)
+	self.leaveBlockScope(enter)
+	self.closeScope()
+
+	self.addProgramInstructions(Jump(jumpIndex - self.getInstructionSize()))
+	self.setProgramInstruction(exitJumpIndex, Jne(self.getInstructionSize()-exitJumpIndex))
+	self.leaveBlockScope(enterIterator)
+	self.closeScope()
+
+	self.closeBlock()
+}
+
+func (self *Compiler) compileWhileStatement(st *ast.WhileStatement, needResult bool) {
+	blockLoop := self.openBlockLoop()
+
+	jumpIndex := self.getInstructionSize()
+	blockLoop.continueBase = jumpIndex
+	conditionExpr := self.compileExpression(st.Condition)
+	self.chooseHandlingGetterExpression(conditionExpr, true)
+	conditionJumpIndex := self.getInstructionSize()
+	self.addProgramInstructions(nil)
+	self.compileStatement(st.Body, needResult)
+	self.addProgramInstructions(Jump(jumpIndex - self.getInstructionSize()))
+	self.setProgramInstruction(conditionJumpIndex, Jne(self.getInstructionSize()-conditionJumpIndex))
+
+	self.closeBlock()
+}
+
+func (self *Compiler) compileDoWhileStatement(st *ast.DoWhileStatement, needResult bool) {
+	blockLoop := self.openBlockLoop()
+
+	jumpIndex := self.getInstructionSize()
+	self.compileStatement(st.Body, needResult)
+	blockLoop.continueBase = self.getInstructionSize()
+	conditionExpr := self.compileExpression(st.Condition)
+	self.chooseHandlingGetterExpression(conditionExpr, true)
+	self.addProgramInstructions(Jeq(jumpIndex - self.getInstructionSize()))
+
+	self.closeBlock()
+}
+
 func (self *Compiler) compileThrowStatement(st *ast.ThrowStatement) {
 	expr := self.compileExpression(st.Argument)
 	self.handlingGetterExpression(expr, true)
-	expr.addSourceMap()
+	self.program.addSourceMap(int(st.StartIndex()) - 1)
 	self.addProgramInstructions(Throw)
 }
 
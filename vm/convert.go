@@ -0,0 +1,500 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+var (
+	bigIntType   = reflect.TypeOf((*big.Int)(nil))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	callableType = reflect.TypeOf(Callable(nil))
+)
+
+// ToValue converts a Go value to a Value. Numbers, strings, booleans, nil and
+// *big.Int become primitives, slices and arrays become arrays, maps and
+// structs become objects holding a copy of their entries or exported fields,
+// pointers to structs are bound by a ReflectObject and funcs become functions.
+// A func(FunctionCall) Value is called as it is, other funcs get their
+// arguments converted by ExportTo, and a non-nil error as the last result of a
+// func is thrown as an Error. Values are returned as they are. A map or slice
+// holding itself becomes an object or array holding itself. It throws a
+// TypeError for types that have no Value form, like channels.
+func (self *VM) ToValue(value any) Value {
+	return self.runtime.toValue(value)
+}
+
+// ExportTo converts value into the Go value target points to. It is the
+// reverse of ToValue: arrays fill slices, objects fill maps and structs,
+// whose fields take the property of the same name or else of the name
+// starting in lower case, and functions fill a Callable or funcs that call
+// them, returning the exception as an error if the func's last result is an
+// error and panicking with it otherwise. null sets the zero value, an empty
+// interface receives value.Export().
+func (self *VM) ExportTo(value Value, target any) error {
+	pointer := reflect.ValueOf(target)
+	if pointer.Kind() != reflect.Pointer || pointer.IsNil() {
+		return errors.New("vm: ExportTo needs a non-nil pointer")
+	}
+	result, err := self.runtime.exportTo(value, pointer.Elem().Type())
+	if err != nil {
+		return err
+	}
+	pointer.Elem().Set(result)
+	return nil
+}
+
+// AssertObject returns the object value holds, objects are held both as Object
+// and *Object.
+func AssertObject(value Value) (*Object, bool) {
+	if value == nil || !value.isObject() {
+		return nil, false
+	}
+	return value.toObject(), true
+}
+
+// NewObject returns an empty object.
+func (self *VM) NewObject() Object {
+	return *self.runtime.newObject()
+}
+
+// NewArray returns an array holding values.
+func (self *VM) NewArray(values ...Value) Object {
+	return *self.runtime.newArray(append(ValueArray{}, values...))
+}
+
+// convertedKey identifies a map, slice or pointer while its elements are
+// converted, a slice also by its length as slices of one array may differ.
+type convertedKey struct {
+	pointer   uintptr
+	valueType reflect.Type
+	length    int
+}
+
+func (self *Runtime) toValue(value any) Value {
+	return self.convertToValue(value, nil)
+}
+
+// convertToValue converts value, converted holds the values made for the maps,
+// slices and pointers value is an element of.
+func (self *Runtime) convertToValue(value any, converted map[convertedKey]Value) Value {
+	switch value := value.(type) {
+	case nil:
+		return Const_Null_Value
+	case Value:
+		return value
+	case *Object:
+		if value == nil {
+			return Const_Null_Value
+		}
+		return *value
+	case *big.Int:
+		if value == nil {
+			return Const_Null_Value
+		}
+		return ToBigIntValue(new(big.Int).Set(value))
+	case func(FunctionCall) Value:
+		if value == nil {
+			return Const_Null_Value
+		}
+		return Object{self.newNativeFun("", 0, value)}
+	}
+	return self.reflectToValue(reflect.ValueOf(value), converted)
+}
+
+func (self *Runtime) reflectToValue(value reflect.Value, converted map[convertedKey]Value) Value {
+	var key convertedKey
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if value.IsNil() {
+			return Const_Null_Value
+		}
+		key = convertedKey{value.Pointer(), value.Type(), 0}
+		if value.Kind() == reflect.Slice {
+			key.length = value.Len()
+		}
+		if result, exists := converted[key]; exists {
+			if result == nil {
+				// A pointer leading back to itself has nothing to hold.
+				return Const_Null_Value
+			}
+			return result
+		}
+		if converted == nil {
+			converted = map[convertedKey]Value{}
+		}
+		converted[key] = nil
+		defer delete(converted, key)
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return ToBooleanValue(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ToIntValue(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return ToBigIntValue(new(big.Int).SetUint64(value.Uint()))
+		}
+		return ToIntValue(int64(value.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return ToFloatValue(value.Float())
+	case reflect.String:
+		return ToStringValue(value.String())
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return Const_Null_Value
+		}
+		if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct {
+			return *self.newReflectObject(value)
+		}
+		return self.convertToValue(value.Elem().Interface(), converted)
+	case reflect.Slice, reflect.Array:
+		values := make(ValueArray, value.Len())
+		array := *self.newArray(values)
+		if value.Kind() == reflect.Slice {
+			converted[key] = array
+		}
+		for i := range values {
+			values[i] = self.convertToValue(value.Index(i).Interface(), converted)
+		}
+		return array
+	case reflect.Map:
+		object := *self.newObject()
+		converted[key] = object
+		iter := value.MapRange()
+		for iter.Next() {
+			object.self.setProperty(fmt.Sprint(iter.Key().Interface()), self.convertToValue(iter.Value().Interface(), converted))
+		}
+		return object
+	case reflect.Struct:
+		object := self.newObject()
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if name := self.fieldName(value.Type(), field); field.IsExported() && name != "" {
+				object.self.setProperty(name, self.convertToValue(value.Field(i).Interface(), converted))
+			}
+		}
+		return *object
+	case reflect.Func:
+		if value.IsNil() {
+			return Const_Null_Value
+		}
+		return self.newGoFunction("", value)
+	}
+	panic(self.newTypeError("Cannot convert %s to a Value", value.Type()))
+}
+
+// newGoFunction wraps a Go func as a native function. Its arguments are
+// converted to the parameter types, missing ones are null.
+func (self *Runtime) newGoFunction(name string, function reflect.Value) Object {
+	functionType := function.Type()
+	return Object{self.newNativeFun(name, functionType.NumIn(), func(call FunctionCall) Value {
+		count := functionType.NumIn()
+		if functionType.IsVariadic() {
+			count = max(count-1, len(call.Arguments))
+		}
+		args := make([]reflect.Value, count)
+		for i := range args {
+			var argType reflect.Type
+			if functionType.IsVariadic() && i >= functionType.NumIn()-1 {
+				argType = functionType.In(functionType.NumIn() - 1).Elem()
+			} else {
+				argType = functionType.In(i)
+			}
+			var arg Value = Const_Null_Value
+			if i < len(call.Arguments) {
+				arg = call.Arguments[i]
+			}
+			value, err := self.exportTo(arg, argType)
+			if err != nil {
+				panic(self.newTypeError("Argument %d: %v", i, err))
+			}
+			args[i] = value
+		}
+		return self.resultsToValue(function.Call(args))
+	})}
+}
+
+// resultsToValue converts the results of a Go func call. A trailing error is
+// thrown if it is not nil, more than one remaining result makes an array.
+func (self *Runtime) resultsToValue(results []reflect.Value) Value {
+	if count := len(results); count > 0 && results[count-1].Type() == errorType {
+		if err := results[count-1]; !err.IsNil() {
+			panic(self.newError(classError, err.Interface().(error).Error()))
+		}
+		results = results[:count-1]
+	}
+	switch len(results) {
+	case 0:
+		return nil
+	case 1:
+		return self.toValue(results[0].Interface())
+	}
+	values := make(ValueArray, len(results))
+	for i, result := range results {
+		values[i] = self.toValue(result.Interface())
+	}
+	return *self.newArray(values)
+}
+
+func typeName(value Value) string {
+	switch {
+	case value.isInt():
+		return "int"
+	case value.isFloat():
+		return "float"
+	case value.isBigInt():
+		return "bigint"
+	case value.isString():
+		return "string"
+	case value.isBool():
+		return "bool"
+	case value.isNull():
+		return "null"
+	}
+	return value.toObject().self.getClassName()
+}
+
+func (self *Runtime) exportTo(value Value, targetType reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		value = Const_Null_Value
+	}
+	if targetType.Kind() == reflect.Interface && targetType.NumMethod() == 0 {
+		if exported := value.Export(); exported != nil {
+			return reflect.ValueOf(exported), nil
+		}
+		return reflect.Zero(targetType), nil
+	}
+	if reflect.TypeOf(value).AssignableTo(targetType) {
+		return reflect.ValueOf(value), nil
+	}
+	if value.isNull() {
+		return reflect.Zero(targetType), nil
+	}
+	if reflectObject, ok := toReflectObject(value); ok {
+		switch {
+		case reflectObject.value.Type().AssignableTo(targetType):
+			return reflectObject.value, nil
+		case reflectObject.value.Type().Elem().AssignableTo(targetType):
+			return reflectObject.value.Elem(), nil
+		}
+	}
+	failed := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", typeName(value), targetType)
+	}
+	result := reflect.New(targetType).Elem()
+	if targetType == bigIntType {
+		if !value.isInt() && !value.isBigInt() {
+			return failed()
+		}
+		return reflect.ValueOf(new(big.Int).Set(toBigInt(value))), nil
+	}
+	switch targetType.Kind() {
+	case reflect.Bool:
+		if !value.isBool() {
+			return failed()
+		}
+		result.SetBool(value.toBool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		integer, ok := toInteger(value)
+		if !ok || !integer.IsInt64() || result.OverflowInt(integer.Int64()) {
+			return failed()
+		}
+		result.SetInt(integer.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		integer, ok := toInteger(value)
+		if !ok || !integer.IsUint64() || result.OverflowUint(integer.Uint64()) {
+			return failed()
+		}
+		result.SetUint(integer.Uint64())
+	case reflect.Float32, reflect.Float64:
+		if !value.isInt() && !value.isFloat() && !value.isBigInt() {
+			return failed()
+		}
+		result.SetFloat(value.toFloat())
+	case reflect.String:
+		if !value.isString() {
+			return failed()
+		}
+		result.SetString(value.toString())
+	case reflect.Slice, reflect.Array:
+		arrayObject, ok := toArrayObject(value)
+		if !ok || targetType.Kind() == reflect.Array && targetType.Len() != len(arrayObject.values) {
+			return failed()
+		}
+		if targetType.Kind() == reflect.Slice {
+			result = reflect.MakeSlice(targetType, len(arrayObject.values), len(arrayObject.values))
+		}
+		for i, element := range arrayObject.values {
+			elementValue, err := self.exportTo(element, targetType.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			result.Index(i).Set(elementValue)
+		}
+	case reflect.Map:
+		if !value.isObject() || targetType.Key().Kind() != reflect.String {
+			return failed()
+		}
+		result = reflect.MakeMap(targetType)
+		iter := value.toObject().self.iterate()
+		for key, element, ok := iter.next(); ok; key, element, ok = iter.next() {
+			elementValue, err := self.exportTo(element, targetType.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("property %s: %w", key.toString(), err)
+			}
+			result.SetMapIndex(reflect.ValueOf(key.toString()).Convert(targetType.Key()), elementValue)
+		}
+	case reflect.Struct:
+		if !value.isObject() {
+			return failed()
+		}
+		object := value.toObject()
+		for i := 0; i < targetType.NumField(); i++ {
+			field := targetType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := self.fieldName(targetType, field)
+			if name == "" {
+				continue
+			}
+			property := object.self.getProperty(name)
+			if property == nil && self.fieldNameMapper == nil {
+				property = object.self.getProperty(strings.ToLower(field.Name[:1]) + field.Name[1:])
+			}
+			if property == nil {
+				continue
+			}
+			fieldValue, err := self.exportTo(property, field.Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			result.Field(i).Set(fieldValue)
+		}
+	case reflect.Pointer:
+		elementValue, err := self.exportTo(value, targetType.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result = reflect.New(targetType.Elem())
+		result.Elem().Set(elementValue)
+	case reflect.Func:
+		if !isCallable(value) {
+			return failed()
+		}
+		if targetType == callableType {
+			callable, _ := self.vm.AssertFunction(value)
+			return reflect.ValueOf(callable), nil
+		}
+		result = self.scriptFunction(value, targetType)
+	default:
+		return failed()
+	}
+	return result, nil
+}
+
+// toInteger returns the integer a number stands for, floats with a fraction
+// have none.
+func toInteger(value Value) (*big.Int, bool) {
+	switch {
+	case value.isInt(), value.isBigInt():
+		return toBigInt(value), true
+	case value.isFloat():
+		floatValue := value.toFloat()
+		if math.IsInf(floatValue, 0) || floatValue != math.Trunc(floatValue) {
+			return nil, false
+		}
+		integer, _ := big.NewFloat(floatValue).Int(nil)
+		return integer, true
+	}
+	return nil, false
+}
+
+func toReflectObject(value Value) (*ReflectObject, bool) {
+	if !value.isObject() {
+		return nil, false
+	}
+	reflectObject, ok := value.toObject().self.(*ReflectObject)
+	return reflectObject, ok
+}
+
+func toArrayObject(value Value) (*ArrayObject, bool) {
+	if !value.isObject() {
+		return nil, false
+	}
+	arrayObject, ok := value.toObject().self.(*ArrayObject)
+	return arrayObject, ok
+}
+
+// scriptFunction makes a Go func of functionType that calls function with
+// null as this.
+func (self *Runtime) scriptFunction(function Value, functionType reflect.Type) reflect.Value {
+	returnsError := functionType.NumOut() > 0 && functionType.Out(functionType.NumOut()-1) == errorType
+	return reflect.MakeFunc(functionType, func(args []reflect.Value) []reflect.Value {
+		var values []Value
+		for i, arg := range args {
+			if functionType.IsVariadic() && i == len(args)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					values = append(values, self.toValue(arg.Index(j).Interface()))
+				}
+				break
+			}
+			values = append(values, self.toValue(arg.Interface()))
+		}
+		results := make([]reflect.Value, functionType.NumOut())
+		for i := range results {
+			results[i] = reflect.Zero(functionType.Out(i))
+		}
+		result, err := self.vm.Call(function, Const_Null_Value, values...)
+		if err == nil && len(results) > 0 && !(returnsError && len(results) == 1) {
+			var value reflect.Value
+			if value, err = self.exportTo(result, functionType.Out(0)); err == nil {
+				results[0] = value
+			}
+		}
+		if err != nil {
+			if !returnsError {
+				panic(err)
+			}
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+		}
+		return results
+	})
+}
+
+// exportValue is Export for values that may be objects, seen holds the
+// results for the objects already exported so cycles are kept.
+func exportValue(value Value, seen map[ObjectImpl]any) any {
+	if !value.isObject() {
+		return value.Export()
+	}
+	object := value.toObject()
+	if exported, ok := seen[object.self]; ok {
+		return exported
+	}
+	switch objectImpl := object.self.(type) {
+	case *ArrayObject:
+		values := make([]any, len(objectImpl.values))
+		seen[objectImpl] = values
+		for i, element := range objectImpl.values {
+			values[i] = exportValue(element, seen)
+		}
+		return values
+	case *FunObject, *NativeFunObject, *ClassFunObject, *ClassObject:
+		return value
+	case *ReflectObject:
+		return objectImpl.value.Interface()
+	}
+	properties := map[string]any{}
+	seen[object.self] = properties
+	iter := object.self.iterate()
+	for key, property, ok := iter.next(); ok; key, property, ok = iter.next() {
+		properties[key.toString()] = exportValue(property, seen)
+	}
+	return properties
+}
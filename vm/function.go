@@ -1,5 +1,7 @@
 package vm
 
+import "fmt"
+
 type BaseFunObject struct {
 	BaseObject
 	funDefinition string
@@ -12,6 +14,17 @@ func (self *BaseFunObject) toLiteral() string {
 	return self.funDefinition
 }
 
+func isCallable(value Value) bool {
+	if !value.isObject() {
+		return false
+	}
+	switch value.toObject().self.(type) {
+	case *FunObject, *NativeFunObject:
+		return true
+	}
+	return false
+}
+
 type FunObject struct {
 	BaseFunObject
 }
@@ -31,8 +44,7 @@ type ClassFunObject struct {
 }
 
 func (self *ClassFunObject) vmCall(vm *VM, n int) {
-	//wait adjust
-	panic("Class constructor cannot be invoked without 'new'")
+	panic(vm.runtime.newTypeError("Class constructor %s cannot be invoked without 'new'", self.getPropertyOrDefault("name", Const_Empty_String_Value).toString()))
 }
 
 func (self *ClassFunObject) classConstruct(runtime *Runtime, args []Value) *Object {
@@ -47,7 +59,9 @@ func (self *ClassFunObject) instanceConstruct(runtime *Runtime, args []Value) *O
 
 func (self *ClassFunObject) construct(runtime *Runtime, thisObj *Object, args []Value) *Object {
 	self.initObject(runtime, thisObj)
-	self.call(runtime, thisObj, args)
+	if _, ex := self.call(runtime, thisObj, args); ex != nil {
+		panic(ex)
+	}
 	return thisObj
 }
 
@@ -122,24 +136,61 @@ func (self *ClassFunObject) call(runtime *Runtime, thisObj *Object, args []Value
 	return vm.pop(), nil
 }
 
-type NativeFunCall struct {
-	this Value
-	args []Value
+// FunctionCall holds the receiver and the arguments of a call to a native
+// function. Arguments is only valid until the function returns.
+type FunctionCall struct {
+	This      Value
+	Arguments []Value
+
+	vm *VM
+}
+
+// Argument returns the argument at index, or null if it was not passed.
+func (self FunctionCall) Argument(index int) Value {
+	if index < len(self.Arguments) {
+		return self.Arguments[index]
+	}
+	return Const_Null_Value
+}
+
+// VM returns the VM the function is called in.
+func (self FunctionCall) VM() *VM {
+	return self.vm
+}
+
+// Throw throws value as a script exception, it does not return.
+func (self FunctionCall) Throw(value Value) {
+	if value == nil {
+		value = Const_Null_Value
+	}
+	panic(value)
+}
+
+// ThrowError throws an Error with the formatted message, it does not return.
+func (self FunctionCall) ThrowError(format string, args ...any) {
+	panic(self.vm.runtime.newError(classError, fmt.Sprintf(format, args...)))
+}
+
+// ThrowTypeError throws a TypeError with the formatted message, it does not
+// return.
+func (self FunctionCall) ThrowTypeError(format string, args ...any) {
+	panic(self.vm.runtime.newTypeError(format, args...))
 }
 
 type NativeFunObject struct {
 	BaseFunObject
 
-	fun func(NativeFunCall) Value
+	fun func(FunctionCall) Value
 }
 
 func (self *NativeFunObject) vmCall(vm *VM, n int) {
 	vm.pushCtx()
 	vm.program = nil
 	vm.sb = vm.sp - n
-	value := self.fun(NativeFunCall{
-		this: vm.stack[vm.sp-n-2],
-		args: vm.stack[vm.sp-n : vm.sp],
+	value := self.fun(FunctionCall{
+		This:      vm.stack[vm.sp-n-2],
+		Arguments: vm.stack[vm.sp-n : vm.sp],
+		vm:        vm,
 	})
 	if value == nil {
 		value = Const_Null_Value
@@ -1,5 +0,0 @@
-package vm
-
-type Global struct {
-	referenceError *Object
-}
@@ -1,8 +1,8 @@
 package vm
 
 import (
-	"fmt"
 	"math"
+	"math/big"
 	"sort"
 	"strings"
 )
@@ -27,13 +27,19 @@ var (
 	Mul _Mul
 	Div _Div
 	Mod _Mod
-
-	AND _AND
-	OR  _OR
-	Not _Not
-	Inc _Inc
-	Dec _Dec
-	Neg _Neg
+	Pow _Pow
+
+	AND    _AND
+	OR     _OR
+	XOR    _XOR
+	SHL    _SHL
+	SHR    _SHR
+	USHR   _USHR
+	Not    _Not
+	BitNot _BitNot
+	Inc    _Inc
+	Dec    _Dec
+	Neg    _Neg
 
 	EQ _EQ
 	NE _NE
@@ -46,14 +52,21 @@ var (
 	Dup                 _Dup
 	SaveResult          _SaveResult
 	InitVar             _InitVar
+	CopyStash           _CopyStash
 	LoadNull            _LoadNull
 	NewObject           _NewObject
 	PushArrayValue      _PushArrayValue
 	GetPropOrElem       _GetPropOrElem
+	SetElem             _SetElem
 	GetPropOrElemCallee _GetPropOrElemCallee
 	LoadDynamicThis     _LoadDynamicThis
 	Throw               _Throw
 	Ret                 _Ret
+	Enumerate           _Enumerate
+	Iterate             _Iterate
+	IterNext            _IterNext
+	IterKey             _IterKey
+	IterValue           _IterValue
 )
 
 type _LoadNull struct{}
@@ -81,8 +94,12 @@ func (self _Add) exec(vm *VM) {
 		value = ToStringValue(left.toString() + right.toString())
 	} else if left.isFloat() || right.isFloat() {
 		value = ToFloatValue(left.toFloat() + right.toFloat())
+	} else if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Add(toBigInt(left), toBigInt(right)))
 	} else {
-		value = ToIntValue(left.toInt() + right.toInt())
+		a, b := left.toInt(), right.toInt()
+		result := a + b
+		value = vm.intResult(result, (a^result)&(b^result) < 0)
 	}
 
 	vm.stack[vm.sp-2] = value
@@ -99,8 +116,12 @@ func (self _Sub) exec(vm *VM) {
 	var value Value
 	if left.isFloat() || right.isFloat() {
 		value = ToFloatValue(left.toFloat() - right.toFloat())
+	} else if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Sub(toBigInt(left), toBigInt(right)))
 	} else {
-		value = ToIntValue(left.toInt() - right.toInt())
+		a, b := left.toInt(), right.toInt()
+		result := a - b
+		value = vm.intResult(result, (a^b)&(a^result) < 0)
 	}
 
 	vm.stack[vm.sp-2] = value
@@ -117,8 +138,11 @@ func (self _Mul) exec(vm *VM) {
 	var value Value
 	if left.isFloat() || right.isFloat() {
 		value = ToFloatValue(left.toFloat() * right.toFloat())
+	} else if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Mul(toBigInt(left), toBigInt(right)))
 	} else {
-		value = ToIntValue(left.toInt() * right.toInt())
+		a, b := left.toInt(), right.toInt()
+		value = vm.intResult(a*b, mulOverflows(a, b))
 	}
 
 	vm.stack[vm.sp-2] = value
@@ -132,7 +156,11 @@ func (self _Div) exec(vm *VM) {
 	left := vm.stack[vm.sp-2]
 	right := vm.stack[vm.sp-1]
 
-	vm.stack[vm.sp-2] = ToFloatValue(left.toFloat() / right.toFloat())
+	if !left.isFloat() && !right.isFloat() && (left.isBigInt() || right.isBigInt()) {
+		vm.stack[vm.sp-2] = ToBigIntValue(new(big.Int).Quo(toBigInt(left), vm.bigIntDivisor(right)))
+	} else {
+		vm.stack[vm.sp-2] = ToFloatValue(left.toFloat() / right.toFloat())
+	}
 	vm.sp--
 	vm.pc++
 }
@@ -146,8 +174,56 @@ func (self _Mod) exec(vm *VM) {
 	var value Value
 	if left.isFloat() || right.isFloat() {
 		value = ToFloatValue(math.Mod(left.toFloat(), right.toFloat()))
+	} else if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Rem(toBigInt(left), vm.bigIntDivisor(right)))
 	} else {
-		value = ToIntValue(left.toInt() % right.toInt())
+		divisor := right.toInt()
+		if divisor == 0 {
+			panic(vm.runtime.newRangeError("Division by zero"))
+		}
+		value = ToIntValue(left.toInt() % divisor)
+	}
+
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
+type _Pow struct{}
+
+func (self _Pow) exec(vm *VM) {
+	left := vm.stack[vm.sp-2]
+	right := vm.stack[vm.sp-1]
+
+	var value Value
+	if !left.isFloat() && !right.isFloat() && (left.isBigInt() || right.isBigInt()) {
+		exponent := toBigInt(right)
+		if exponent.Sign() < 0 {
+			panic(vm.runtime.newRangeError("Exponent must be non-negative"))
+		}
+		value = ToBigIntValue(vm.powBigInt(toBigInt(left), exponent))
+	} else if !left.isFloat() && !right.isFloat() && right.toInt() >= 0 {
+		base, exponent, result := left.toInt(), right.toInt(), int64(1)
+		overflow := false
+		for exponent > 0 {
+			if exponent&1 == 1 {
+				overflow = overflow || mulOverflows(result, base)
+				result *= base
+			}
+			exponent >>= 1
+			if exponent > 0 {
+				overflow = overflow || mulOverflows(base, base)
+				base *= base
+			}
+		}
+		if overflow && !vm.checkedArithmetic {
+			// Powers are promoted to float rather than wrapped around.
+			value = ToFloatValue(math.Pow(left.toFloat(), right.toFloat()))
+		} else {
+			value = vm.intResult(result, overflow)
+		}
+	} else {
+		value = ToFloatValue(math.Pow(left.toFloat(), right.toFloat()))
 	}
 
 	vm.stack[vm.sp-2] = value
@@ -161,7 +237,12 @@ func (self _AND) exec(vm *VM) {
 	left := vm.stack[vm.sp-2]
 	right := vm.stack[vm.sp-1]
 
-	value := ToIntValue(left.toInt() & right.toInt())
+	var value Value
+	if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).And(toBigInt(left), toBigInt(right)))
+	} else {
+		value = ToIntValue(left.toInt() & right.toInt())
+	}
 
 	vm.stack[vm.sp-2] = value
 	vm.sp--
@@ -174,7 +255,82 @@ func (self _OR) exec(vm *VM) {
 	left := vm.stack[vm.sp-2]
 	right := vm.stack[vm.sp-1]
 
-	value := ToIntValue(left.toInt() | right.toInt())
+	var value Value
+	if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Or(toBigInt(left), toBigInt(right)))
+	} else {
+		value = ToIntValue(left.toInt() | right.toInt())
+	}
+
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
+type _XOR struct{}
+
+func (self _XOR) exec(vm *VM) {
+	left := vm.stack[vm.sp-2]
+	right := vm.stack[vm.sp-1]
+
+	var value Value
+	if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Xor(toBigInt(left), toBigInt(right)))
+	} else {
+		value = ToIntValue(left.toInt() ^ right.toInt())
+	}
+
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
+type _SHL struct{}
+
+func (self _SHL) exec(vm *VM) {
+	left := vm.stack[vm.sp-2]
+	right := vm.stack[vm.sp-1]
+
+	var value Value
+	if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(vm.shiftBigInt(toBigInt(left), toBigInt(right)))
+	} else {
+		value = ToIntValue(left.toInt() << (uint64(right.toInt()) & 63))
+	}
+
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
+type _SHR struct{}
+
+func (self _SHR) exec(vm *VM) {
+	left := vm.stack[vm.sp-2]
+	right := vm.stack[vm.sp-1]
+
+	var value Value
+	if left.isBigInt() || right.isBigInt() {
+		value = ToBigIntValue(vm.shiftBigInt(toBigInt(left), new(big.Int).Neg(toBigInt(right))))
+	} else {
+		value = ToIntValue(left.toInt() >> (uint64(right.toInt()) & 63))
+	}
+
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
+type _USHR struct{}
+
+func (self _USHR) exec(vm *VM) {
+	left := vm.stack[vm.sp-2]
+	right := vm.stack[vm.sp-1]
+
+	if left.isBigInt() || right.isBigInt() {
+		panic(vm.runtime.newTypeError("BigInts have no unsigned right shift, use >> instead"))
+	}
+	value := ToIntValue(int64(uint64(left.toInt()) >> (uint64(right.toInt()) & 63)))
 
 	vm.stack[vm.sp-2] = value
 	vm.sp--
@@ -196,6 +352,19 @@ func (self _Not) exec(vm *VM) {
 	vm.pc++
 }
 
+type _BitNot struct{}
+
+func (self _BitNot) exec(vm *VM) {
+	value := vm.stack[vm.sp-1]
+
+	if value.isBigInt() {
+		vm.stack[vm.sp-1] = ToBigIntValue(new(big.Int).Not(toBigInt(value)))
+	} else {
+		vm.stack[vm.sp-1] = ToIntValue(^value.toInt())
+	}
+	vm.pc++
+}
+
 type _Inc struct{}
 
 func (self _Inc) exec(vm *VM) {
@@ -203,8 +372,10 @@ func (self _Inc) exec(vm *VM) {
 
 	if value.isFloat() {
 		value = ToFloatValue(value.toFloat() + 1.0)
+	} else if value.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Add(toBigInt(value), big.NewInt(1)))
 	} else {
-		value = ToIntValue(value.toInt() + 1)
+		value = vm.intResult(value.toInt()+1, value.toInt() == math.MaxInt64)
 	}
 
 	vm.stack[vm.sp-1] = value
@@ -218,8 +389,10 @@ func (self _Dec) exec(vm *VM) {
 
 	if value.isFloat() {
 		value = ToFloatValue(value.toFloat() - 1.0)
+	} else if value.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Sub(toBigInt(value), big.NewInt(1)))
 	} else {
-		value = ToIntValue(value.toInt() - 1)
+		value = vm.intResult(value.toInt()-1, value.toInt() == math.MinInt64)
 	}
 
 	vm.stack[vm.sp-1] = value
@@ -233,8 +406,10 @@ func (self _Neg) exec(vm *VM) {
 
 	if value.isFloat() {
 		value = ToFloatValue(-value.toFloat())
+	} else if value.isBigInt() {
+		value = ToBigIntValue(new(big.Int).Neg(toBigInt(value)))
 	} else {
-		value = ToIntValue(-value.toInt())
+		value = vm.intResult(-value.toInt(), value.toInt() == math.MinInt64)
 	}
 
 	vm.stack[vm.sp-1] = value
@@ -335,6 +510,8 @@ func lessComp(left Value, right Value) Value {
 	var less bool
 	if left.isString() && right.isString() {
 		less = strings.Compare(left.toString(), right.toString()) < 0
+	} else if left.isBigInt() || right.isBigInt() {
+		less = compareBigFloat(left, right) == -1
 	} else if left.isInt() || left.isFloat() && right.isInt() || right.isFloat() {
 		if left.isFloat() || right.isFloat() {
 			less = left.toFloat() < right.toFloat()
@@ -348,6 +525,66 @@ func lessComp(left Value, right Value) Value {
 	return Const_Bool_False_Value
 }
 
+// intResult returns the result of an int64 operation, a VM with checked
+// arithmetic throws a RangeError instead of wrapping around on overflow.
+func (self *VM) intResult(value int64, overflow bool) Value {
+	if overflow && self.checkedArithmetic {
+		panic(self.runtime.newRangeError("Integer overflow"))
+	}
+	return ToIntValue(value)
+}
+
+func mulOverflows(a int64, b int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	result := a * b
+	return result/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64
+}
+
+func (self *VM) bigIntDivisor(value Value) *big.Int {
+	divisor := toBigInt(value)
+	if divisor.Sign() == 0 {
+		panic(self.runtime.newRangeError("Division by zero"))
+	}
+	return divisor
+}
+
+// maxBigIntBits caps the size of BigInt shifts and powers, a larger result
+// throws a RangeError instead of exhausting memory.
+const maxBigIntBits = 1 << 24
+
+// shiftBigInt shifts value left by count bits, or right for a negative count.
+func (self *VM) shiftBigInt(value *big.Int, count *big.Int) *big.Int {
+	bitLength := int64(value.BitLen())
+	if count.Sign() < 0 {
+		if count.CmpAbs(big.NewInt(bitLength)) > 0 {
+			// Every bit is shifted out, leaving 0 or -1.
+			return new(big.Int).Rsh(value, uint(bitLength))
+		}
+		return new(big.Int).Rsh(value, uint(-count.Int64()))
+	}
+	if value.Sign() == 0 {
+		return value
+	}
+	if !count.IsInt64() || count.Int64() > maxBigIntBits-bitLength {
+		panic(self.runtime.newRangeError("Maximum BigInt size exceeded"))
+	}
+	return new(big.Int).Lsh(value, uint(count.Int64()))
+}
+
+// powBigInt raises base to a non-negative exponent, a result of more than
+// maxBigIntBits throws a RangeError.
+func (self *VM) powBigInt(base *big.Int, exponent *big.Int) *big.Int {
+	if base.CmpAbs(big.NewInt(1)) > 0 {
+		// The result has at least (bits of base - 1) * exponent bits.
+		if !exponent.IsInt64() || exponent.Int64() > maxBigIntBits/int64(base.BitLen()-1) {
+			panic(self.runtime.newRangeError("Maximum BigInt size exceeded"))
+		}
+	}
+	return new(big.Int).Exp(base, exponent, nil)
+}
+
 type Jeq int
 
 func (self Jeq) exec(vm *VM) {
@@ -428,6 +665,28 @@ func (self _Dup) exec(vm *VM) {
 	vm.pc++
 }
 
+// DupN pushes copies of the top n values.
+type DupN int
+
+func (self DupN) exec(vm *VM) {
+	for i := 0; i < int(self); i++ {
+		vm.push(vm.stack[vm.sp-int(self)])
+	}
+	vm.pc++
+}
+
+// DupUnder copies the top value below the n values under it, keeping the old
+// value of a postfix update while the target is stored.
+type DupUnder int
+
+func (self DupUnder) exec(vm *VM) {
+	top := vm.stack[vm.sp-1]
+	vm.push(top)
+	copy(vm.stack[vm.sp-int(self)-1:vm.sp], vm.stack[vm.sp-int(self)-2:vm.sp-1])
+	vm.stack[vm.sp-int(self)-2] = top
+	vm.pc++
+}
+
 type _SaveResult struct{}
 
 func (self _SaveResult) exec(vm *VM) {
@@ -439,10 +698,15 @@ func (self _SaveResult) exec(vm *VM) {
 type ResolveVar string
 
 func (self ResolveVar) exec(vm *VM) {
-	vm.refStack.add(&ObjectRef{
+	ref := ObjectRef{
 		refObject: vm.runtime.globalObject,
 		refName:   string(self),
-	})
+	}
+	if binding, exists := vm.runtime.globalLexicals[ref.refName]; exists {
+		vm.refStack.add(&GlobalLexicalRef{ref, binding})
+	} else {
+		vm.refStack.add(&ref)
+	}
 	vm.pc++
 }
 
@@ -459,6 +723,16 @@ type PutVar int
 
 func (self PutVar) exec(vm *VM) {
 	ref := vm.refStack.pop()
+	if ref, ok := ref.(*GlobalLexicalRef); ok {
+		if ref.get() == nil {
+			vm.throw(vm.runtime.newUninitializedError(ref.name()))
+			return
+		}
+		if ref.binding.isConst {
+			vm.throw(vm.runtime.newTypeError("Assignment to constant variable '%s'", ref.name()))
+			return
+		}
+	}
 	ref.set(vm.stack[vm.sp-1])
 	vm.sp += int(self)
 	vm.pc++
@@ -470,7 +744,7 @@ func (self LoadVar) exec(vm *VM) {
 	name := string(self)
 	value := vm.getDefining(name)
 	if value == nil {
-		vm.throw(vm.runtime.newReferenceError(name))
+		vm.throw(vm.runtime.newUnresolvedError(name))
 		return
 	}
 	vm.push(value)
@@ -500,6 +774,18 @@ func (self InitStackVar1) exec(vm *VM) {
 	vm.pc++
 }
 
+// CheckInitialized throws when the let or const binding just loaded is still in
+// its temporal dead zone, which block and function entry mark with nil.
+type CheckInitialized string
+
+func (self CheckInitialized) exec(vm *VM) {
+	if vm.stack[vm.sp-1] == nil {
+		vm.throw(vm.runtime.newUninitializedError(string(self)))
+		return
+	}
+	vm.pc++
+}
+
 type LoadStackVar int
 
 func (self LoadStackVar) exec(vm *VM) {
@@ -596,6 +882,19 @@ func (self LoadStashVar) exec(vm *VM) {
 	vm.pc++
 }
 
+// CopyStash gives the next iteration of a for loop with let bindings a fresh
+// copy of the loop stash, so closures keep the values of their own iteration.
+type _CopyStash struct{}
+
+func (self _CopyStash) exec(vm *VM) {
+	stash := vm.stash
+	vm.stash = &Stash{
+		outer:  stash.outer,
+		values: append(ValueArray(nil), stash.values...),
+	}
+	vm.pc++
+}
+
 type PutStashVar int
 
 func (self PutStashVar) exec(vm *VM) {
@@ -608,7 +907,8 @@ func (self LoadDynamicCallee) exec(vm *VM) {
 	name := string(self)
 	value := vm.getDefining(name)
 	if value == nil {
-		//wait adjust
+		vm.throw(vm.runtime.newUnresolvedError(name))
+		return
 	}
 	vm.push(Const_Null_Value)
 	vm.push(value)
@@ -616,11 +916,21 @@ func (self LoadDynamicCallee) exec(vm *VM) {
 }
 
 type BindDefining struct {
-	funs []string
-	vars []string
+	funs   []string
+	vars   []string
+	lets   []string
+	consts []string
 }
 
 func (self BindDefining) exec(vm *VM) {
+	for _, names := range [][]string{self.funs, self.vars, self.lets, self.consts} {
+		for _, name := range names {
+			if _, exists := vm.runtime.globalLexicals[name]; exists {
+				vm.throw(vm.runtime.newSyntaxError("Identifier '%s' has already been declared", name))
+				return
+			}
+		}
+	}
 	start := vm.sp - len(self.funs)
 	for i, fun := range self.funs {
 		value := vm.stack[start+i]
@@ -629,6 +939,14 @@ func (self BindDefining) exec(vm *VM) {
 	for _, v := range self.vars {
 		vm.setDefining(v, nil)
 	}
+	for _, l := range self.lets {
+		vm.setDefining(l, nil)
+		vm.runtime.globalLexicals[l] = &globalLexical{}
+	}
+	for _, c := range self.consts {
+		vm.setDefining(c, nil)
+		vm.runtime.globalLexicals[c] = &globalLexical{isConst: true}
+	}
 	vm.sp = start
 	vm.pc++
 }
@@ -646,18 +964,39 @@ type AddProp string
 func (self AddProp) exec(vm *VM) {
 	obj := vm.stack[vm.sp-2]
 	value := vm.stack[vm.sp-1]
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot set property '%s' of %s", string(self), obj.toLiteral()))
+		return
+	}
 	obj.toObject().self.setProperty(string(self), value)
 	vm.sp--
 	vm.pc++
 }
 
+// SetProp sets a property like AddProp but leaves the value instead of the
+// object, as the result of an assignment.
+type SetProp string
+
+func (self SetProp) exec(vm *VM) {
+	obj := vm.stack[vm.sp-2]
+	value := vm.stack[vm.sp-1]
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot set property '%s' of %s", string(self), obj.toLiteral()))
+		return
+	}
+	obj.toObject().self.setProperty(string(self), value)
+	vm.stack[vm.sp-2] = value
+	vm.sp--
+	vm.pc++
+}
+
 type GetProp string
 
 func (self GetProp) exec(vm *VM) {
 	obj := vm.stack[vm.sp-1]
-	if obj == nil {
-		//wait adjust
-		panic(fmt.Sprintf("Cannot read property '%s' of undefined", self))
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot read property '%s' of %s", string(self), obj.toLiteral()))
+		return
 	}
 	value := obj.toObject().self.getPropertyOrDefault(string(self), Const_Null_Value)
 	vm.stack[vm.sp-1] = value
@@ -668,9 +1007,9 @@ type GetPropCallee string
 
 func (self GetPropCallee) exec(vm *VM) {
 	obj := vm.stack[vm.sp-1]
-	if obj == nil {
-		//wait adjust
-		panic(fmt.Sprintf("Cannot read property '%s' of undefined", self))
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot read property '%s' of %s", string(self), obj.toLiteral()))
+		return
 	}
 	value := obj.toObject().self.getPropertyOrDefault(string(self), Const_Null_Value)
 	vm.push(value)
@@ -682,9 +1021,9 @@ type _GetPropOrElem struct{}
 func (self _GetPropOrElem) exec(vm *VM) {
 	obj := vm.stack[vm.sp-2]
 	prop := vm.stack[vm.sp-1]
-	if obj == nil {
-		//wait adjust
-		panic(fmt.Sprintf("Cannot read property '%s' of undefined", self))
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot read property '%s' of %s", prop.toString(), obj.toLiteral()))
+		return
 	}
 	value := obj.toObject().getOrDefault(prop, Const_Null_Value)
 	vm.stack[vm.sp-2] = value
@@ -697,15 +1036,31 @@ type _GetPropOrElemCallee struct{}
 func (self _GetPropOrElemCallee) exec(vm *VM) {
 	obj := vm.stack[vm.sp-2]
 	prop := vm.stack[vm.sp-1]
-	if obj == nil {
-		//wait adjust
-		panic(fmt.Sprintf("Cannot read property '%s' of undefined", self))
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot read property '%s' of %s", prop.toString(), obj.toLiteral()))
+		return
 	}
 	value := obj.toObject().getOrDefault(prop, Const_Null_Value)
 	vm.stack[vm.sp-1] = value
 	vm.pc++
 }
 
+type _SetElem struct{}
+
+func (self _SetElem) exec(vm *VM) {
+	obj := vm.stack[vm.sp-3]
+	prop := vm.stack[vm.sp-2]
+	value := vm.stack[vm.sp-1]
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("Cannot set property '%s' of %s", prop.toString(), obj.toLiteral()))
+		return
+	}
+	obj.toObject().set(vm.runtime, prop, value)
+	vm.stack[vm.sp-3] = value
+	vm.sp -= 2
+	vm.pc++
+}
+
 type NewArray uint32
 
 func (self NewArray) exec(vm *VM) {
@@ -726,6 +1081,29 @@ func (self _PushArrayValue) exec(vm *VM) {
 	vm.pc++
 }
 
+// Concat joins the string forms of the top n stack values, as a template literal does.
+type Concat int
+
+func (self Concat) exec(vm *VM) {
+	var builder strings.Builder
+	for _, value := range vm.stack[vm.sp-int(self) : vm.sp] {
+		builder.WriteString(value.toString())
+	}
+	vm.sp -= int(self)
+	vm.push(ToStringValue(builder.String()))
+	vm.pc++
+}
+
+type NewTemplateObject struct {
+	cooked []string
+	raw    []string
+}
+
+func (self NewTemplateObject) exec(vm *VM) {
+	vm.push(vm.runtime.newTemplateObject(self.cooked, self.raw))
+	vm.pc++
+}
+
 type NewFun struct {
 	funDefinition string
 	name          string
@@ -757,6 +1135,10 @@ func (self EnterFun) exec(vm *VM) {
 		for index := range vs {
 			vs[index] = Const_Null_Value
 		}
+		ls := vm.stack[ss-self.stackSize : ss]
+		for index := range ls {
+			ls[index] = nil
+		}
 		vm.args = self.args
 		vm.sp = ss
 	} else if self.stackSize > 0 {
@@ -901,6 +1283,7 @@ func (self NewClass) exec(vm *VM) {
 	vm.sp = sp
 
 	classObject := obj.self.(*ClassObject)
+	classObject.name = self.name
 	classObject.classDefinition = self.source
 	sort.SliceStable(self.constructors, func(i, j int) bool {
 		return self.constructors[i].argNum < self.constructors[j].argNum
@@ -1036,8 +1419,8 @@ func (self Call) exec(vm *VM) {
 	n := int(self)
 	value := vm.stack[vm.sp-1-n]
 	if !value.isObject() {
-		//wait adjust
-		panic("Value is not a function: " + value.toString())
+		vm.throw(vm.runtime.newTypeError("Value is not a function: %s", value.toLiteral()))
+		return
 	}
 	object := value.toObject()
 	object.self.vmCall(vm, n)
@@ -1049,10 +1432,75 @@ func (self New) exec(vm *VM) {
 	argNum := int(self)
 	sp := vm.sp - argNum
 	obj := vm.stack[sp-1]
-	classObject := obj.toObject().self.(*ClassObject)
-	constructor := classObject.findConstructor(argNum)
-	vm.stack[sp-1] = constructor.instanceConstruct(vm.runtime, vm.stack[sp:vm.sp])
-	vm.sp = sp
+	if !obj.isObject() {
+		vm.throw(vm.runtime.newTypeError("%s is not a constructor", obj.toLiteral()))
+		return
+	}
+	var value Value
+	switch callee := obj.toObject().self.(type) {
+	case *ClassObject:
+		constructor := callee.findConstructor(argNum)
+		if constructor == nil {
+			vm.throw(vm.runtime.newTypeError("Class %s has no constructor accepting %d arguments", callee.name, argNum))
+			return
+		}
+		value = constructor.instanceConstruct(vm.runtime, vm.stack[sp:vm.sp])
+	case *NativeFunObject:
+		value = callee.fun(FunctionCall{
+			This:      Const_Null_Value,
+			Arguments: vm.stack[sp:vm.sp],
+			vm:        vm,
+		})
+		if value == nil {
+			value = Const_Null_Value
+		}
+	default:
+		vm.throw(vm.runtime.newTypeError("%s is not a constructor", obj.toLiteral()))
+		return
+	}
+	// The callee sits above the null receiver every callee load pushes.
+	vm.stack[sp-2] = value
+	vm.sp = sp - 1
+	vm.pc++
+}
+
+type _Enumerate struct{}
+
+func (self _Enumerate) exec(vm *VM) {
+	vm.stack[vm.sp-1] = vm.enumerate(vm.stack[vm.sp-1])
+	vm.pc++
+}
+
+type _Iterate struct{}
+
+func (self _Iterate) exec(vm *VM) {
+	vm.stack[vm.sp-1] = vm.iterate(vm.stack[vm.sp-1])
+	vm.pc++
+}
+
+type _IterNext struct{}
+
+func (self _IterNext) exec(vm *VM) {
+	iteratorObject := vm.stack[vm.sp-1].toObject().self.(*IteratorObject)
+	if iteratorObject.advance() {
+		vm.stack[vm.sp-1] = Const_Bool_True_Value
+	} else {
+		vm.stack[vm.sp-1] = Const_Bool_False_Value
+	}
+	vm.pc++
+}
+
+type _IterKey struct{}
+
+func (self _IterKey) exec(vm *VM) {
+	vm.stack[vm.sp-1] = vm.stack[vm.sp-1].toObject().self.(*IteratorObject).key
+	vm.pc++
+}
+
+type _IterValue struct{}
+
+func (self _IterValue) exec(vm *VM) {
+	vm.stack[vm.sp-1] = vm.stack[vm.sp-1].toObject().self.(*IteratorObject).value
 	vm.pc++
 }
 
@@ -0,0 +1,135 @@
+package vm
+
+import "sort"
+
+const (
+	classIterator = "Iterator"
+
+	iteratorMethodName = "iterator"
+	hasNextMethodName  = "hasNext"
+	nextMethodName     = "next"
+)
+
+// iterator yields the entries of an iterable object one at a time.
+// for-in loops bind the key (and optionally the value), for-of loops bind the value.
+type iterator interface {
+	next() (key Value, value Value, ok bool)
+}
+
+type IteratorObject struct {
+	BaseObject
+	iterator iterator
+	key      Value
+	value    Value
+}
+
+func (self *IteratorObject) advance() bool {
+	key, value, ok := self.iterator.next()
+	self.key, self.value = key, value
+	return ok
+}
+
+type arrayIterator struct {
+	array *ArrayObject
+	index int
+}
+
+func (self *arrayIterator) next() (Value, Value, bool) {
+	if self.index >= self.array.values.size() {
+		return nil, nil, false
+	}
+	key, value := ToIntValue(int64(self.index)), self.array.values[self.index]
+	self.index++
+	return key, value, true
+}
+
+type propertyIterator struct {
+	object ObjectImpl
+	names  []string
+	index  int
+}
+
+func (self *propertyIterator) next() (Value, Value, bool) {
+	for self.index < len(self.names) {
+		name := self.names[self.index]
+		self.index++
+		if value := self.object.getProperty(name); value != nil {
+			return ToStringValue(name), value, true
+		}
+	}
+	return nil, nil, false
+}
+
+// protocolIterator drives an iterator object returned by a script defined
+// iterator() method through its hasNext() and next() methods.
+type protocolIterator struct {
+	vm       *VM
+	iterator Value
+	index    int64
+}
+
+func (self *protocolIterator) callMethod(name string) Value {
+	method := self.iterator.toObject().self.getProperty(name)
+	if method == nil || !isCallable(method) {
+		panic(self.vm.runtime.newTypeError("Iterator has no method '%s'", name))
+	}
+	value, ex := self.vm.call(method, self.iterator)
+	if ex != nil {
+		panic(ex)
+	}
+	return value
+}
+
+func (self *protocolIterator) next() (Value, Value, bool) {
+	if !self.callMethod(hasNextMethodName).toBool() {
+		return nil, nil, false
+	}
+	key, value := ToIntValue(self.index), self.callMethod(nextMethodName)
+	self.index++
+	return key, value, true
+}
+
+func (self *ArrayObject) iterate() iterator {
+	return &arrayIterator{array: self}
+}
+
+func (self *BaseObject) iterate() iterator {
+	names := make([]string, 0, len(self.valueMapping))
+	for name := range self.valueMapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &propertyIterator{object: self, names: names}
+}
+
+// enumerate walks the own entries of an object for for-in loops, whether or
+// not the object defines an iterator() method.
+func (self *VM) enumerate(iterable Value) *Object {
+	if !iterable.isObject() {
+		panic(self.runtime.newTypeError("%s is not iterable", iterable.toLiteral()))
+	}
+	return self.runtime.newIterator(iterable.toObject().self.iterate())
+}
+
+// iterate prefers a script defined iterator() method for for-of loops and
+// falls back to the own entries of the object.
+func (self *VM) iterate(iterable Value) *Object {
+	if !iterable.isObject() {
+		panic(self.runtime.newTypeError("%s is not iterable", iterable.toLiteral()))
+	}
+	object := iterable.toObject()
+	var iter iterator
+	if method := object.self.getProperty(iteratorMethodName); method != nil && isCallable(method) {
+		value, ex := self.call(method, iterable)
+		if ex != nil {
+			panic(ex)
+		}
+		if !value.isObject() {
+			panic(self.runtime.newTypeError("Result of the iterator method is not an object"))
+		}
+		iter = &protocolIterator{vm: self, iterator: value}
+	} else {
+		iter = object.self.iterate()
+	}
+	return self.runtime.newIterator(iter)
+}
@@ -6,12 +6,20 @@ import (
 )
 
 const (
-	thisBindingName = "this"
+	thisBindingName     = "this"
+	iteratorBindingName = "%iterator"
 
 	classObject   = "Object"
 	classGlobal   = "Global"
 	classArray    = "Array"
 	classFunction = "Function"
+
+	classError              = "Error"
+	classTypeError          = "TypeError"
+	classReferenceError     = "ReferenceError"
+	classRangeError         = "RangeError"
+	classSyntaxError        = "SyntaxError"
+	classStackOverflowError = "StackOverflowError"
 )
 
 type ObjectImpl interface {
@@ -23,6 +31,7 @@ type ObjectImpl interface {
 	setProperty(string, Value)
 	equals(objectImpl ObjectImpl) bool
 	vmCall(vm *VM, n int)
+	iterate() iterator
 }
 
 type ObjectType int
@@ -37,6 +46,9 @@ type BaseObject struct {
 	objectType   ObjectType
 	className    string
 	valueMapping map[string]Value
+	// inLiteral is set while toLiteral writes the object, so that an object
+	// holding itself is written as [Circular].
+	inLiteral bool
 }
 
 func (self *BaseObject) init() {
@@ -48,6 +60,11 @@ func (self *BaseObject) getClassName() string {
 }
 
 func (self *BaseObject) toLiteral() string {
+	if self.inLiteral {
+		return "[Circular]"
+	}
+	self.inLiteral = true
+	defer func() { self.inLiteral = false }()
 	if self.objectType == normalObject {
 		var literals []string
 		for name, value := range self.valueMapping {
@@ -87,12 +104,12 @@ func (self *BaseObject) equals(objectImpl ObjectImpl) bool {
 }
 
 func (self *BaseObject) vmCall(vm *VM, n int) {
-	//wait adjust
-	panic("Not a function: " + self.className)
+	panic(vm.runtime.newTypeError("Not a function: %s", self.className))
 }
 
 type ClassObject struct {
 	BaseObject
+	name            string
 	classDefinition string
 	constructors    []*ClassFunObject
 }
@@ -101,6 +118,10 @@ func (self *ClassObject) toLiteral() string {
 	return self.classDefinition
 }
 
+func (self *ClassObject) vmCall(vm *VM, n int) {
+	panic(vm.runtime.newTypeError("Class constructor %s cannot be invoked without 'new'", self.name))
+}
+
 func (self *ClassObject) findConstructor(argNum int) *ClassFunObject {
 	for _, constructor := range self.constructors {
 		if constructor.argNum <= argNum {
@@ -109,3 +130,16 @@ func (self *ClassObject) findConstructor(argNum int) *ClassFunObject {
 	}
 	return nil
 }
+
+type ErrorObject struct {
+	BaseObject
+}
+
+func (self *ErrorObject) toLiteral() string {
+	name := self.getPropertyOrDefault("name", ToStringValue(self.className)).toString()
+	message := self.getPropertyOrDefault("message", Const_Empty_String_Value).toString()
+	if message == "" {
+		return name
+	}
+	return name + ": " + message
+}
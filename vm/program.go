@@ -3,6 +3,7 @@ package vm
 import (
 	"fmt"
 	"github.com/istrangers/demolanguage/file"
+	"sort"
 )
 
 type SourceMapItem struct {
@@ -60,20 +61,51 @@ func (self *Program) addSourceMap(pos int) {
 	self.sourceMaps.add(SourceMapItem{int(self.instructions.size()), pos})
 }
 
+// DumpInstructions logs the values and instructions of the program and of the
+// functions it defines, colored with ANSI escapes when colored is set.
+func (self *Program) DumpInstructions(colored bool, logger func(format string, args ...interface{})) {
+	self.dumpInstructionsByIndent("", colored, logger)
+}
+
+func (self *Program) getSourceOffset(pc int) int {
+	index := sort.Search(self.sourceMaps.size(), func(i int) bool {
+		return self.sourceMaps[i].pc > pc
+	}) - 1
+	if index < 0 {
+		return -1
+	}
+	return self.sourceMaps[index].pos
+}
+
+func (self *Program) getPosition(pc int) *file.Position {
+	if self.source == nil {
+		return nil
+	}
+	offset := self.getSourceOffset(pc)
+	if offset < 0 {
+		return nil
+	}
+	return self.source.Position(offset)
+}
+
 func (self *Program) dumpInstructions(logger func(format string, args ...interface{})) {
-	self.dumpInstructionsByIndent("", logger)
+	self.dumpInstructionsByIndent("", true, logger)
 }
 
 const (
 	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
 )
 
-func colorize(color, message string) string {
-	return fmt.Sprintf("%s%s", color, message)
+func colorize(colored bool, color, message string) string {
+	if !colored {
+		return message
+	}
+	return fmt.Sprintf("%s%s%s", color, message, colorReset)
 }
 
-func (self *Program) dumpInstructionsByIndent(indent string, logger func(format string, args ...interface{})) {
-	logger(colorize(colorGreen, "values: %+v"), self.values)
+func (self *Program) dumpInstructionsByIndent(indent string, colored bool, logger func(format string, args ...interface{})) {
+	logger(colorize(colored, colorGreen, "values: %+v"), self.values)
 	//dumpInitFields := func(initFields *Program) {
 	//	i := indent + ">"
 	//	logger("%s ---- init_fields:", i)
@@ -81,14 +113,14 @@ func (self *Program) dumpInstructionsByIndent(indent string, logger func(format
 	//	logger("%s ----", i)
 	//}
 	for pc, ins := range self.instructions {
-		logger(colorize(colorGreen, "%s %d: %T(%v)"), indent, pc, ins, ins)
+		logger(colorize(colored, colorGreen, "%s %d: %T(%v)"), indent, pc, ins, ins)
 		var prg *Program
 		switch f := ins.(type) {
 		case *NewFun:
 			prg = f.program
 		}
 		if prg != nil {
-			prg.dumpInstructionsByIndent(indent+">", logger)
+			prg.dumpInstructionsByIndent(indent+">", colored, logger)
 		}
 	}
 }
@@ -22,3 +22,16 @@ func (self *ObjectRef) get() Value {
 func (self *ObjectRef) set(value Value) {
 	self.refObject.self.setProperty(self.refName, value)
 }
+
+// globalLexical is a let or const declaration of the global scope, its value
+// lives in the global object and stays nil until the declaration has run.
+type globalLexical struct {
+	isConst bool
+}
+
+// GlobalLexicalRef refers to the global object property of a let or const
+// declaration of the global scope.
+type GlobalLexicalRef struct {
+	ObjectRef
+	binding *globalLexical
+}
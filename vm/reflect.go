@@ -0,0 +1,242 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldNameMapper maps the exported fields and methods of Go structs to
+// property names, an empty name hides the member from scripts.
+type FieldNameMapper interface {
+	FieldName(structType reflect.Type, field reflect.StructField) string
+	MethodName(structType reflect.Type, method reflect.Method) string
+}
+
+type tagFieldNameMapper struct {
+	tagName      string
+	uncapMethods bool
+}
+
+func (self tagFieldNameMapper) FieldName(_ reflect.Type, field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup(self.tagName)
+	if !ok {
+		return field.Name
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "-" {
+		if name == "" {
+			return field.Name
+		}
+		return name
+	}
+	return ""
+}
+
+func (self tagFieldNameMapper) MethodName(_ reflect.Type, method reflect.Method) string {
+	if self.uncapMethods {
+		return uncap(method.Name)
+	}
+	return method.Name
+}
+
+// TagFieldNameMapper names fields by the part before the comma of their tag
+// tagName, like json:"name,omitempty", hides fields tagged "-" and keeps the
+// Go name of untagged fields. Methods keep their Go name, or start in lower
+// case if uncapMethods is set.
+func TagFieldNameMapper(tagName string, uncapMethods bool) FieldNameMapper {
+	return tagFieldNameMapper{tagName, uncapMethods}
+}
+
+type uncapFieldNameMapper struct{}
+
+func (self uncapFieldNameMapper) FieldName(_ reflect.Type, field reflect.StructField) string {
+	return uncap(field.Name)
+}
+
+func (self uncapFieldNameMapper) MethodName(_ reflect.Type, method reflect.Method) string {
+	return uncap(method.Name)
+}
+
+// UncapFieldNameMapper names fields and methods in lowerCamelCase, Name
+// becomes name and URLPath urlPath.
+func UncapFieldNameMapper() FieldNameMapper {
+	return uncapFieldNameMapper{}
+}
+
+// uncap lowers the leading upper case letters of name, all but the last one
+// if a lower case letter follows them.
+func uncap(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// SetFieldNameMapper selects how the fields and methods of Go structs are
+// named in scripts, nil keeps their Go names.
+func (self *VM) SetFieldNameMapper(mapper FieldNameMapper) {
+	self.runtime.fieldNameMapper = mapper
+	self.runtime.reflectTypes = nil
+}
+
+// reflectType holds the members of a struct type by property name.
+type reflectType struct {
+	fieldNames []string
+	fields     map[string][]int
+	methods    map[string]int
+}
+
+func (self *Runtime) fieldName(structType reflect.Type, field reflect.StructField) string {
+	if self.fieldNameMapper == nil {
+		return field.Name
+	}
+	return self.fieldNameMapper.FieldName(structType, field)
+}
+
+func (self *Runtime) reflectTypeOf(pointerType reflect.Type) *reflectType {
+	if cached, ok := self.reflectTypes[pointerType]; ok {
+		return cached
+	}
+	structType := pointerType.Elem()
+	result := &reflectType{fields: map[string][]int{}, methods: map[string]int{}}
+	for _, field := range reflect.VisibleFields(structType) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if name := self.fieldName(structType, field); name != "" {
+			if _, exists := result.fields[name]; !exists {
+				result.fieldNames = append(result.fieldNames, name)
+			}
+			result.fields[name] = field.Index
+		}
+	}
+	for i := 0; i < pointerType.NumMethod(); i++ {
+		method := pointerType.Method(i)
+		name := method.Name
+		if self.fieldNameMapper != nil {
+			name = self.fieldNameMapper.MethodName(structType, method)
+		}
+		if _, isField := result.fields[name]; name != "" && !isField {
+			result.methods[name] = i
+		}
+	}
+	if self.reflectTypes == nil {
+		self.reflectTypes = map[reflect.Type]*reflectType{}
+	}
+	self.reflectTypes[pointerType] = result
+	return result
+}
+
+// ReflectObject binds a pointer to a Go struct, scripts read and write its
+// exported fields and call its methods. Scalar and nested struct fields write
+// through to the struct, slice and map fields are read as copies, so changing
+// them takes assigning the whole field.
+type ReflectObject struct {
+	BaseObject
+	runtime     *Runtime
+	value       reflect.Value
+	reflectType *reflectType
+	// methods holds the functions of the methods looked up so far, so a method
+	// is the same value each time.
+	methods map[string]Value
+}
+
+func (self *Runtime) newReflectObject(value reflect.Value) *Object {
+	reflectObject := &ReflectObject{
+		runtime:     self,
+		value:       value,
+		reflectType: self.reflectTypeOf(value.Type()),
+	}
+	reflectObject.objectType = normalObject
+	reflectObject.className = value.Type().Elem().Name()
+	if reflectObject.className == "" {
+		reflectObject.className = classObject
+	}
+	reflectObject.init()
+	return &Object{reflectObject}
+}
+
+func (self *ReflectObject) field(name string) (reflect.Value, bool) {
+	index, ok := self.reflectType.fields[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	field, err := self.value.Elem().FieldByIndexErr(index)
+	return field, err == nil
+}
+
+func (self *ReflectObject) toLiteral() string {
+	var literals []string
+	for _, name := range self.reflectType.fieldNames {
+		value := self.getProperty(name)
+		valueFormat := "%s"
+		if value.isString() {
+			valueFormat = "\"%s\""
+		}
+		literals = append(literals, fmt.Sprintf("%s: %s", name, fmt.Sprintf(valueFormat, value.toLiteral())))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(literals, ","))
+}
+
+func (self *ReflectObject) getValueByIndex(prop IntValue, defaultValue Value) Value {
+	return self.getPropertyOrDefault(prop.toString(), defaultValue)
+}
+
+func (self *ReflectObject) getProperty(name string) Value {
+	if field, ok := self.field(name); ok {
+		if field.Kind() == reflect.Struct && field.CanAddr() {
+			// Nested structs are bound too, so writes reach the original.
+			return *self.runtime.newReflectObject(field.Addr())
+		}
+		return self.runtime.toValue(field.Interface())
+	}
+	if index, ok := self.reflectType.methods[name]; ok {
+		method, ok := self.methods[name]
+		if !ok {
+			method = self.runtime.newGoFunction(name, self.value.Method(index))
+			if self.methods == nil {
+				self.methods = map[string]Value{}
+			}
+			self.methods[name] = method
+		}
+		return method
+	}
+	return nil
+}
+
+func (self *ReflectObject) getPropertyOrDefault(name string, defaultValue Value) Value {
+	if value := self.getProperty(name); value != nil {
+		return value
+	}
+	return defaultValue
+}
+
+func (self *ReflectObject) setProperty(name string, value Value) {
+	field, ok := self.field(name)
+	if !ok || !field.CanSet() {
+		panic(self.runtime.newTypeError("Cannot set property '%s' of %s", name, self.className))
+	}
+	fieldValue, err := self.runtime.exportTo(value, field.Type())
+	if err != nil {
+		panic(self.runtime.newTypeError("Cannot set property '%s' of %s: %v", name, self.className, err))
+	}
+	field.Set(fieldValue)
+}
+
+func (self *ReflectObject) equals(objectImpl ObjectImpl) bool {
+	other, ok := objectImpl.(*ReflectObject)
+	return ok && self.value.Type() == other.value.Type() && self.value.Pointer() == other.value.Pointer()
+}
+
+func (self *ReflectObject) iterate() iterator {
+	return &propertyIterator{object: self, names: self.reflectType.fieldNames}
+}
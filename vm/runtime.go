@@ -2,8 +2,13 @@ package vm
 
 import (
 	"fmt"
+	"github.com/istrangers/demolanguage/file"
 	"io"
+	"math"
+	"math/big"
 	"os"
+	"reflect"
+	"strings"
 )
 
 type StackFrame struct {
@@ -12,30 +17,94 @@ type StackFrame struct {
 	pc           int
 }
 
+func (self StackFrame) FunctionName() string {
+	return self.functionName
+}
+
+func (self StackFrame) Position() *file.Position {
+	if self.program == nil {
+		return nil
+	}
+	return self.program.getPosition(self.pc)
+}
+
+func (self StackFrame) String() string {
+	var location string
+	if self.program == nil {
+		location = "native"
+	} else if position := self.Position(); position != nil {
+		location = position.String()
+	} else {
+		location = "unknown"
+	}
+	if self.functionName == "" {
+		return location
+	}
+	return fmt.Sprintf("%s (%s)", self.functionName, location)
+}
+
 type StackFrameArray []StackFrame
 
+func (self StackFrameArray) String() string {
+	var builder strings.Builder
+	for i, frame := range self {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString("\tat ")
+		builder.WriteString(frame.String())
+	}
+	return builder.String()
+}
+
 type Exception struct {
 	value Value
 	stack StackFrameArray
 }
 
+func (self *Exception) Value() Value {
+	return self.value
+}
+
+func (self *Exception) Stack() StackFrameArray {
+	return self.stack
+}
+
+func (self *Exception) StackTrace() string {
+	return self.stack.String()
+}
+
+func (self *Exception) Error() string {
+	message := "Uncaught " + self.value.toLiteral()
+	if len(self.stack) == 0 {
+		return message
+	}
+	return message + "\n" + self.StackTrace()
+}
+
 type Runtime struct {
-	global       *Global
 	globalObject *Object
-	vm           *VM
+	// globalLexicals holds the let and const declarations of the global scope,
+	// which later programs run by the same VM may not declare again.
+	globalLexicals map[string]*globalLexical
+	vm             *VM
+
+	fieldNameMapper FieldNameMapper
+	// reflectTypes caches the members of the struct types bound so far.
+	reflectTypes map[reflect.Type]*reflectType
 }
 
 var RuntimePrintWrite io.Writer = os.Stdout
 
 func CreateRuntime() *Runtime {
 	runtime := &Runtime{
-		global: &Global{},
+		globalLexicals: make(map[string]*globalLexical),
 		globalObject: &Object{self: &BaseObject{
 			className: classGlobal,
 			valueMapping: map[string]Value{
-				"println": Object{&NativeFunObject{fun: func(call NativeFunCall) Value {
+				"println": Object{&NativeFunObject{fun: func(call FunctionCall) Value {
 					var literals []any
-					for _, arg := range call.args {
+					for _, arg := range call.Arguments {
 						literals = append(literals, arg.toLiteral())
 					}
 					fmt.Fprintln(RuntimePrintWrite, literals...)
@@ -44,6 +113,10 @@ func CreateRuntime() *Runtime {
 			},
 		}},
 	}
+	runtime.globalObject.self.setProperty("BigInt", runtime.newBigIntFunction())
+	for _, className := range []string{classError, classTypeError, classReferenceError, classRangeError, classSyntaxError, classStackOverflowError} {
+		runtime.globalObject.self.setProperty(className, runtime.newErrorConstructor(className))
+	}
 	runtime.vm = &VM{
 		runtime:          runtime,
 		sb:               -1,
@@ -85,6 +158,34 @@ func (self *Runtime) newArray(values ValueArray) *Object {
 	return &Object{arrayObject}
 }
 
+// newTemplateObject creates the strings array handed to a template tag, the raw
+// source of each part is kept on its own array under "raw".
+func (self *Runtime) newTemplateObject(cooked []string, raw []string) *Object {
+	toValues := func(strings []string) ValueArray {
+		values := make(ValueArray, 0, len(strings))
+		for _, str := range strings {
+			values = append(values, ToStringValue(str))
+		}
+		return values
+	}
+	templateObject := self.newArray(toValues(cooked))
+	arrayObject := templateObject.self.(*ArrayObject)
+	arrayObject.valueMapping = make(map[string]Value, len(arrayProps)+1)
+	for name, value := range arrayProps {
+		arrayObject.valueMapping[name] = value
+	}
+	arrayObject.valueMapping["raw"] = self.newArray(toValues(raw))
+	arrayObject.ownProperties = true
+	return templateObject
+}
+
+func (self *Runtime) newIterator(iter iterator) *Object {
+	iteratorObject := &IteratorObject{iterator: iter}
+	iteratorObject.className = classIterator
+	iteratorObject.init()
+	return &Object{iteratorObject}
+}
+
 func (self *Runtime) newFun(name string, length int) *FunObject {
 	funObject := &FunObject{}
 	funObject.className = classFunction
@@ -103,14 +204,101 @@ func (self *Runtime) newClassFun(name string, length int) *ClassFunObject {
 	return funObject
 }
 
-func (self *Runtime) createReferenceError(msg string) *Object {
-	if self.global.referenceError == nil {
-		self.global.referenceError = self.newObject()
-	}
-	self.global.referenceError.self.setProperty("message", ToStringValue(msg))
-	return self.global.referenceError
+func (self *Runtime) newNativeFun(name string, length int, fun func(FunctionCall) Value) *NativeFunObject {
+	funObject := &NativeFunObject{fun: fun}
+	funObject.className = classFunction
+	funObject.init()
+	funObject.setProperty("name", ToStringValue(name))
+	funObject.setProperty("length", ToIntValue(int64(length)))
+	return funObject
+}
+
+// newBigIntFunction creates the BigInt conversion function, which accepts ints,
+// integral floats, booleans and strings of digits.
+func (self *Runtime) newBigIntFunction() Object {
+	return Object{self.newNativeFun("BigInt", 1, func(call FunctionCall) Value {
+		if len(call.Arguments) == 0 {
+			panic(self.newTypeError("Cannot convert undefined to a BigInt"))
+		}
+		value := call.Arguments[0]
+		switch {
+		case value.isBigInt():
+			return value
+		case value.isInt(), value.isBool():
+			return ToBigIntValue(big.NewInt(value.toInt()))
+		case value.isFloat():
+			floatValue := value.toFloat()
+			if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) || floatValue != math.Trunc(floatValue) {
+				panic(self.newRangeError("The number %s cannot be converted to a BigInt because it is not an integer", value.toString()))
+			}
+			intValue, _ := big.NewFloat(floatValue).Int(nil)
+			return ToBigIntValue(intValue)
+		case value.isString():
+			digits := strings.TrimSpace(value.toString())
+			if digits == "" {
+				return ToBigIntValue(new(big.Int))
+			}
+			if intValue, ok := new(big.Int).SetString(digits, 0); ok {
+				return ToBigIntValue(intValue)
+			}
+		}
+		panic(self.newTypeError("Cannot convert %s to a BigInt", value.toString()))
+	})}
+}
+
+func (self *Runtime) newError(className string, msg string) Object {
+	errorObject := &ErrorObject{}
+	errorObject.objectType = normalObject
+	errorObject.className = className
+	errorObject.init()
+	errorObject.setProperty("name", ToStringValue(className))
+	errorObject.setProperty("message", ToStringValue(msg))
+	return Object{errorObject}
+}
+
+func (self *Runtime) newErrorConstructor(className string) Object {
+	return Object{self.newNativeFun(className, 1, func(call FunctionCall) Value {
+		msg := ""
+		if len(call.Arguments) > 0 {
+			msg = call.Arguments[0].toString()
+		}
+		return self.newError(className, msg)
+	})}
+}
+
+func (self *Runtime) newTypeError(format string, args ...any) Object {
+	return self.newError(classTypeError, fmt.Sprintf(format, args...))
+}
+
+func (self *Runtime) newRangeError(format string, args ...any) Object {
+	return self.newError(classRangeError, fmt.Sprintf(format, args...))
+}
+
+func (self *Runtime) newSyntaxError(format string, args ...any) Object {
+	return self.newError(classSyntaxError, fmt.Sprintf(format, args...))
+}
+
+func (self *Runtime) newStackOverflowError() Object {
+	return self.newError(classStackOverflowError, "Maximum call stack size exceeded")
+}
+
+func (self *Runtime) createReferenceError(msg string) Object {
+	return self.newError(classReferenceError, msg)
 }
 
 func (self *Runtime) newReferenceError(name string) Value {
-	return self.createReferenceError(fmt.Sprintf("ReferenceError: '%s' is not defined", name))
+	return self.createReferenceError(fmt.Sprintf("'%s' is not defined", name))
+}
+
+func (self *Runtime) newUninitializedError(name string) Value {
+	return self.createReferenceError(fmt.Sprintf("Cannot access '%s' before initialization", name))
+}
+
+// newUnresolvedError reports a global name without a value, which is either a
+// let or const declaration that has not run yet or not declared at all.
+func (self *Runtime) newUnresolvedError(name string) Value {
+	if _, exists := self.globalLexicals[name]; exists {
+		return self.newUninitializedError(name)
+	}
+	return self.newReferenceError(name)
 }
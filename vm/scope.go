@@ -7,6 +7,10 @@ type Binding struct {
 
 	isArg   bool
 	inStash bool
+	// isLexical marks let and const bindings, which are unreadable before their
+	// declaration has run, isConst additionally forbids assignments.
+	isLexical bool
+	isConst   bool
 }
 
 func (self *Binding) getAccessPointsByScope(scope *Scope) *[]int {
@@ -66,11 +70,9 @@ func (self *Scope) bindName(name string) (*Binding, bool) {
 		return b, true
 	}
 	binding := &Binding{
-		self,
-		name,
-		make(map[*Scope]*[]int),
-		false,
-		false,
+		scope:        self,
+		name:         name,
+		accessPoints: make(map[*Scope]*[]int),
 	}
 	self.bindings = append(self.bindings, binding)
 	self.bindingMapping[name] = binding
@@ -134,7 +136,7 @@ func (self *Scope) finaliseVarAlloc(stackOffset int) (int, int) {
 		if self.isDynamic || binding.inStash {
 			for scope, aps := range binding.accessPoints {
 				deepLevel := scope.needStashDeepLevel(self)
-				index := (deepLevel << 24) | stackIndex
+				index := (deepLevel << 24) | stashIndex
 				program := scope.program
 				if isThis {
 
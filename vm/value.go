@@ -1,12 +1,15 @@
 package vm
 
 import (
+	"math"
+	"math/big"
 	"strconv"
 )
 
 type Value interface {
 	isInt() bool
 	isFloat() bool
+	isBigInt() bool
 	isString() bool
 	isBool() bool
 	isNull() bool
@@ -21,6 +24,18 @@ type Value interface {
 
 	equals(Value) bool
 	sameAs(Value) bool
+
+	// Export returns the Go form of the value: int64, float64, *big.Int,
+	// string, bool, nil, []any for arrays and map[string]any for objects.
+	// Bound Go structs return their pointer, functions and classes are
+	// returned as they are.
+	Export() any
+}
+
+// Literal returns value written the way a script writes it, which is how the
+// command line and the repl print results.
+func Literal(value Value) string {
+	return value.toLiteral()
 }
 
 type ValueArray []Value
@@ -65,6 +80,10 @@ func (self IntValue) isFloat() bool {
 	return false
 }
 
+func (self IntValue) isBigInt() bool {
+	return false
+}
+
 func (self IntValue) isString() bool {
 	return false
 }
@@ -105,6 +124,9 @@ func (self IntValue) equals(value Value) bool {
 	if self.sameAs(value) {
 		return true
 	}
+	if value.isBigInt() {
+		return value.equals(self)
+	}
 	return self.toFloat() == value.toFloat()
 }
 
@@ -119,6 +141,10 @@ func (self IntValue) toLiteral() string {
 	return self.toString()
 }
 
+func (self IntValue) Export() any {
+	return int64(self)
+}
+
 func ToIntValue(value int64) IntValue {
 	return IntValue(value)
 }
@@ -133,6 +159,10 @@ func (self FloatValue) isFloat() bool {
 	return true
 }
 
+func (self FloatValue) isBigInt() bool {
+	return false
+}
+
 func (self FloatValue) isString() bool {
 	return false
 }
@@ -173,6 +203,9 @@ func (self FloatValue) equals(value Value) bool {
 	if self.sameAs(value) {
 		return true
 	}
+	if value.isBigInt() {
+		return value.equals(self)
+	}
 	return self.toFloat() == value.toFloat()
 }
 
@@ -187,10 +220,127 @@ func (self FloatValue) toLiteral() string {
 	return self.toString()
 }
 
+func (self FloatValue) Export() any {
+	return float64(self)
+}
+
 func ToFloatValue(value float64) FloatValue {
 	return FloatValue(value)
 }
 
+// BigIntValue is an arbitrary-precision integer, its big.Int is never mutated.
+type BigIntValue struct {
+	value *big.Int
+}
+
+func (self BigIntValue) isInt() bool {
+	return false
+}
+
+func (self BigIntValue) isFloat() bool {
+	return false
+}
+
+func (self BigIntValue) isBigInt() bool {
+	return true
+}
+
+func (self BigIntValue) isString() bool {
+	return false
+}
+
+func (self BigIntValue) isBool() bool {
+	return false
+}
+
+func (self BigIntValue) isNull() bool {
+	return false
+}
+
+func (self BigIntValue) isObject() bool {
+	return false
+}
+
+func (self BigIntValue) toInt() int64 {
+	return self.value.Int64()
+}
+
+func (self BigIntValue) toFloat() float64 {
+	value, _ := new(big.Float).SetInt(self.value).Float64()
+	return value
+}
+
+func (self BigIntValue) toString() string {
+	return self.value.String()
+}
+
+func (self BigIntValue) toBool() bool {
+	return self.value.Sign() > 0
+}
+
+func (self BigIntValue) toObject() *Object {
+	return nil
+}
+
+func (self BigIntValue) equals(value Value) bool {
+	switch {
+	case value.isBigInt(), value.isInt():
+		return self.value.Cmp(toBigInt(value)) == 0
+	case value.isFloat():
+		return compareBigFloat(self, value) == 0
+	case value.isString():
+		other, ok := new(big.Int).SetString(value.toString(), 0)
+		return ok && self.value.Cmp(other) == 0
+	}
+	return false
+}
+
+func (self BigIntValue) sameAs(value Value) bool {
+	if value.isBigInt() {
+		return self.value.Cmp(value.(BigIntValue).value) == 0
+	}
+	return false
+}
+
+func (self BigIntValue) toLiteral() string {
+	return self.toString() + "n"
+}
+
+func (self BigIntValue) Export() any {
+	return new(big.Int).Set(self.value)
+}
+
+func ToBigIntValue(value *big.Int) BigIntValue {
+	return BigIntValue{value}
+}
+
+// toBigInt converts an operand of a BigInt operation, other values go through toInt.
+func toBigInt(value Value) *big.Int {
+	if value.isBigInt() {
+		return value.(BigIntValue).value
+	}
+	return big.NewInt(value.toInt())
+}
+
+// compareBigFloat compares two numbers of which at least one is a BigInt, NaN
+// compares as unordered and yields 2.
+func compareBigFloat(left Value, right Value) int {
+	toBigFloat := func(value Value) *big.Float {
+		if value.isBigInt() {
+			return new(big.Float).SetInt(value.(BigIntValue).value)
+		}
+		if math.IsNaN(value.toFloat()) {
+			return nil
+		}
+		return big.NewFloat(value.toFloat())
+	}
+	leftFloat, rightFloat := toBigFloat(left), toBigFloat(right)
+	if leftFloat == nil || rightFloat == nil {
+		return 2
+	}
+	return leftFloat.Cmp(rightFloat)
+}
+
 type StringValue string
 
 func (self StringValue) isInt() bool {
@@ -201,6 +351,10 @@ func (self StringValue) isFloat() bool {
 	return false
 }
 
+func (self StringValue) isBigInt() bool {
+	return false
+}
+
 func (self StringValue) isString() bool {
 	return true
 }
@@ -257,6 +411,10 @@ func (self StringValue) toLiteral() string {
 	return string(self)
 }
 
+func (self StringValue) Export() any {
+	return string(self)
+}
+
 func ToStringValue(value string) StringValue {
 	if value == string(Const_Empty_String_Value) {
 		return Const_Empty_String_Value
@@ -274,6 +432,10 @@ func (self BoolValue) isFloat() bool {
 	return false
 }
 
+func (self BoolValue) isBigInt() bool {
+	return false
+}
+
 func (self BoolValue) isString() bool {
 	return false
 }
@@ -334,6 +496,10 @@ func (self BoolValue) toLiteral() string {
 	return self.toString()
 }
 
+func (self BoolValue) Export() any {
+	return bool(self)
+}
+
 func ToBooleanValue(value bool) BoolValue {
 	if value {
 		return Const_Bool_True_Value
@@ -351,6 +517,10 @@ func (self NullValue) isFloat() bool {
 	return false
 }
 
+func (self NullValue) isBigInt() bool {
+	return false
+}
+
 func (self NullValue) isString() bool {
 	return false
 }
@@ -399,6 +569,10 @@ func (self NullValue) toLiteral() string {
 	return string(self)
 }
 
+func (self NullValue) Export() any {
+	return nil
+}
+
 type Object struct {
 	self ObjectImpl
 }
@@ -411,6 +585,10 @@ func (self Object) isFloat() bool {
 	return false
 }
 
+func (self Object) isBigInt() bool {
+	return false
+}
+
 func (self Object) isString() bool {
 	return false
 }
@@ -462,9 +640,84 @@ func (self Object) toLiteral() string {
 	return self.self.toLiteral()
 }
 
+func (self Object) Export() any {
+	return exportValue(self, map[ObjectImpl]any{})
+}
+
 func (self *Object) getOrDefault(prop Value, defaultValue Value) Value {
 	if prop.isInt() {
 		return self.self.getValueByIndex(prop.(IntValue), defaultValue)
 	}
 	return self.self.getPropertyOrDefault(prop.toString(), defaultValue)
 }
+
+// set writes the element or property prop of the object, the counterpart of
+// getOrDefault.
+func (self *Object) set(runtime *Runtime, prop Value, value Value) {
+	if arrayObject, ok := self.self.(*ArrayObject); ok && prop.isInt() {
+		index := prop.toInt()
+		if index < 0 {
+			panic(runtime.newRangeError("Invalid array index: %d", index))
+		}
+		arrayObject.setValueByIndex(int(index), value)
+		return
+	}
+	self.self.setProperty(prop.toString(), value)
+}
+
+// ClassName returns the class of the object, like Object, Array or Function.
+func (self Object) ClassName() string {
+	return self.self.getClassName()
+}
+
+// Get returns the property name of the object, or nil if it is not set.
+func (self Object) Get(name string) Value {
+	return self.self.getProperty(name)
+}
+
+// Set sets the property name of the object.
+func (self Object) Set(name string, value Value) {
+	if value == nil {
+		value = Const_Null_Value
+	}
+	self.self.setProperty(name, value)
+}
+
+// Keys returns the keys a for-in loop visits, the property names of an object
+// in sorted order or the indexes of an array.
+func (self Object) Keys() []string {
+	var keys []string
+	iter := self.self.iterate()
+	for key, _, ok := iter.next(); ok; key, _, ok = iter.next() {
+		keys = append(keys, key.toString())
+	}
+	return keys
+}
+
+// Length returns the number of elements of an array, or 0 for other objects.
+func (self Object) Length() int {
+	if arrayObject, ok := self.self.(*ArrayObject); ok {
+		return len(arrayObject.values)
+	}
+	return 0
+}
+
+// GetIndex returns the element at index of an array, or nil if the object is
+// not an array or index is out of range.
+func (self Object) GetIndex(index int) Value {
+	if arrayObject, ok := self.self.(*ArrayObject); ok && index >= 0 && index < len(arrayObject.values) {
+		return arrayObject.values[index]
+	}
+	return nil
+}
+
+// SetIndex sets the element at index of an array, growing it with nulls if
+// index is past its end. It does nothing if the object is not an array.
+func (self Object) SetIndex(index int, value Value) {
+	if value == nil {
+		value = Const_Null_Value
+	}
+	if arrayObject, ok := self.self.(*ArrayObject); ok && index >= 0 {
+		arrayObject.setValueByIndex(index, value)
+	}
+}
@@ -1,6 +1,11 @@
 package vm
 
-import "github.com/istrangers/demolanguage/parser"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
 type ValueStack ValueArray
 
@@ -104,6 +109,8 @@ type VM struct {
 	args int
 
 	maxCallStackSize int
+	// checkedArithmetic makes int64 overflow throw a RangeError instead of wrapping.
+	checkedArithmetic bool
 
 	stash     *Stash
 	callStack CallStack
@@ -112,6 +119,43 @@ type VM struct {
 	tryStack  TryStack
 
 	result Value
+
+	// interrupted is checked before each instruction, interruptReason is
+	// guarded by interruptLock as Interrupt may be called from any goroutine.
+	interrupted     atomic.Bool
+	interruptLock   sync.Mutex
+	interruptReason any
+}
+
+// InterruptedError is returned by a run that was stopped by Interrupt. It
+// cannot be caught by scripts, neither catch nor finally blocks run.
+type InterruptedError struct {
+	reason any
+	stack  StackFrameArray
+}
+
+// Reason returns the value passed to Interrupt.
+func (self *InterruptedError) Reason() any {
+	return self.reason
+}
+
+func (self *InterruptedError) Stack() StackFrameArray {
+	return self.stack
+}
+
+func (self *InterruptedError) Error() string {
+	message := fmt.Sprintf("Interrupted: %v", self.reason)
+	if len(self.stack) == 0 {
+		return message
+	}
+	return message + "\n" + self.stack.String()
+}
+
+// Unwrap returns the reason if it is an error, like the error of a cancelled
+// context.
+func (self *InterruptedError) Unwrap() error {
+	err, _ := self.reason.(error)
+	return err
 }
 
 func CreateVM() *VM {
@@ -119,22 +163,154 @@ func CreateVM() *VM {
 	return runtime.vm
 }
 
-func (self *VM) RunScript(script string) (Value, error) {
-	parser := parser.CreateParser(1, "", script, true, true)
-	program, err := parser.Parse()
+// SetCheckedArithmetic selects whether int arithmetic that overflows int64 throws
+// a RangeError rather than wrapping around.
+func (self *VM) SetCheckedArithmetic(checked bool) {
+	self.checkedArithmetic = checked
+}
+
+// Set defines the global name as value converted by ToValue.
+func (self *VM) Set(name string, value any) {
+	self.runtime.globalObject.self.setProperty(name, self.ToValue(value))
+}
+
+// Get returns the global name, or nil if it is not defined.
+func (self *VM) Get(name string) Value {
+	return self.runtime.globalObject.self.getProperty(name)
+}
+
+// RegisterFunc defines the global function name that runs fun.
+func (self *VM) RegisterFunc(name string, fun func(FunctionCall) Value) {
+	self.runtime.globalObject.self.setProperty(name, Object{self.runtime.newNativeFun(name, 0, fun)})
+}
+
+// RegisterModule defines the global object name holding members converted by
+// ToValue, a func(FunctionCall) Value member becomes a method that gets the
+// module as This.
+func (self *VM) RegisterModule(name string, members map[string]any) Object {
+	module := self.runtime.newObjectByClass(name)
+	for memberName, member := range members {
+		if fun, ok := member.(func(FunctionCall) Value); ok {
+			module.self.setProperty(memberName, Object{self.runtime.newNativeFun(memberName, 0, fun)})
+		} else {
+			module.self.setProperty(memberName, self.ToValue(member))
+		}
+	}
+	self.runtime.globalObject.self.setProperty(name, *module)
+	return *module
+}
+
+// Interrupt stops the running script, or else the next one, before its next
+// instruction, the run returns an *InterruptedError holding reason. It is
+// safe to call from any goroutine.
+func (self *VM) Interrupt(reason any) {
+	self.interruptLock.Lock()
+	self.interruptReason = reason
+	self.interruptLock.Unlock()
+	self.interrupted.Store(true)
+}
+
+// ClearInterrupt drops an interrupt that did not stop a run yet.
+func (self *VM) ClearInterrupt() {
+	self.interruptLock.Lock()
+	self.interruptReason = nil
+	self.interruptLock.Unlock()
+	self.interrupted.Store(false)
+}
+
+func (self *VM) interrupt() {
+	self.interruptLock.Lock()
+	reason := self.interruptReason
+	self.interruptReason = nil
+	self.interrupted.Store(false)
+	self.interruptLock.Unlock()
+	panic(&InterruptedError{
+		reason: reason,
+		stack:  self.captureStack(make(StackFrameArray, 0, self.callStack.size()+1), 0),
+	})
+}
+
+// RunScriptContext runs script like RunScript and interrupts it once ctx is
+// done, the returned *InterruptedError wraps ctx.Err().
+func (self *VM) RunScriptContext(ctx context.Context, script string) (Value, error) {
+	program, err := Compile("", script)
 	if err != nil {
 		return nil, err
 	}
-	compiler := CreateCompiler()
-	compiler.compile(program)
-	self.program = compiler.program
-	self.pc = 0
-	self.result = nil
-	self.runTry()
-	if self.result == Const_Null_Value {
-		return nil, nil
+	return self.RunProgramContext(ctx, program)
+}
+
+// RunProgramContext runs program like RunProgram and interrupts it once ctx is
+// done.
+func (self *VM) RunProgramContext(ctx context.Context, program *Program) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &InterruptedError{reason: err}
 	}
-	return self.result, nil
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		self.Interrupt(ctx.Err())
+		close(done)
+	})
+	defer func() {
+		if !stop() {
+			// The interrupt may come after the run ended, it must not stop the
+			// next one.
+			<-done
+			self.ClearInterrupt()
+		}
+	}()
+	return self.RunProgram(program)
+}
+
+func (self *VM) RunScript(script string) (Value, error) {
+	program, err := Compile("", script)
+	if err != nil {
+		return nil, err
+	}
+	return self.RunProgram(program)
+}
+
+func (self *VM) RunProgram(program *Program) (Value, error) {
+	return self.interruptible(func() (Value, error) {
+		self.program = program
+		self.pc = 0
+		self.result = nil
+		if ex := self.runTry(); ex != nil {
+			return nil, ex
+		}
+		if self.result == Const_Null_Value {
+			return nil, nil
+		}
+		return self.result, nil
+	})
+}
+
+// interruptible runs f and returns an interrupt unwinding it as its error,
+// after resetting the stacks and context it left behind. Within another run
+// the interrupt is raised again so that run stops too.
+func (self *VM) interruptible(f func() (Value, error)) (result Value, err error) {
+	var ctx Context
+	self.saveCtx(&ctx)
+	callStackSize, tryStackSize, refStackSize, sp, stash := self.callStack.size(), self.tryStack.size(), self.refStack.size(), self.sp, self.stash
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			interruptedError, ok := recovered.(*InterruptedError)
+			if !ok {
+				panic(recovered)
+			}
+			self.callStack = self.callStack[:callStackSize]
+			self.tryStack = self.tryStack[:tryStackSize]
+			_ = self.restoreStacks(refStackSize)
+			self.sp, self.stash = sp, stash
+			self.clearStack()
+			self.restoreCtx(ctx)
+			if callStackSize > 0 || tryStackSize > 0 {
+				self.Interrupt(interruptedError.reason)
+			}
+			result, err = nil, interruptedError
+		}
+	}()
+	return f()
 }
 
 func (self *VM) run() {
@@ -142,6 +318,9 @@ func (self *VM) run() {
 		if self.pc < 0 || self.pc >= self.getInstructionSize() {
 			break
 		}
+		if self.interrupted.Load() {
+			self.interrupt()
+		}
 		self.execInstruction(self.pc)
 	}
 }
@@ -229,8 +408,7 @@ func (self *VM) restoreCtx(ctx Context) {
 
 func (self *VM) pushCtx() {
 	if self.callStack.size() > self.maxCallStackSize {
-		//wait adjust
-		panic("StackOverflowError")
+		panic(self.runtime.newStackOverflowError())
 	}
 	ctx := Context{}
 	self.saveCtx(&ctx)
@@ -276,7 +454,7 @@ func getFunctionName(stack ValueStack, sb int) string {
 }
 
 func (self *VM) captureStack(stackFrameArray StackFrameArray, ctxOffset int) StackFrameArray {
-	if self.program != nil || self.sb > 0 {
+	if (self.program != nil || self.sb > 0) && self.pc >= 0 {
 		var functionName string
 		if self.program != nil {
 			functionName = self.program.functionName
@@ -287,7 +465,7 @@ func (self *VM) captureStack(stackFrameArray StackFrameArray, ctxOffset int) Sta
 	}
 	for i := self.callStack.size() - 1; i > ctxOffset-1; i-- {
 		stackFrame := self.callStack[i]
-		if stackFrame.program != nil || stackFrame.sb > 0 {
+		if (stackFrame.program != nil || stackFrame.sb > 0) && stackFrame.pc >= 0 {
 			var functionName string
 			if stackFrame.program != nil {
 				functionName = stackFrame.program.functionName
@@ -314,6 +492,11 @@ func (self *VM) handlingThrow(arg any) *Exception {
 	ex := self.formatToException(arg)
 	if ex.stack == nil {
 		ex.stack = self.captureStack(make(StackFrameArray, 0, self.callStack.size()+1), 0)
+		if errorObject, ok := ex.value.(Object); ok {
+			if _, ok := errorObject.self.(*ErrorObject); ok && errorObject.self.getProperty("stack") == nil {
+				errorObject.self.setProperty("stack", ToStringValue(errorObject.toLiteral()+"\n"+ex.StackTrace()))
+			}
+		}
 	}
 	for self.tryStack.size() > 0 {
 		tryFrame := &self.tryStack[self.tryStack.size()-1]
@@ -371,15 +554,88 @@ func (self *VM) popTryFrame() {
 }
 
 func (self *VM) runTryInner() (ex *Exception) {
-	//defer func() {
-	//	if err := recover(); err != nil {
-	//		ex = self.handlingThrow(err)
-	//	}
-	//}()
-	self.run()
+	return self.try(self.run)
+}
+
+func (self *VM) try(f func()) (ex *Exception) {
+	defer func() {
+		if err := recover(); err != nil {
+			switch err.(type) {
+			case *Exception, Value:
+				ex = self.handlingThrow(err)
+			default:
+				panic(err)
+			}
+		}
+	}()
+	f()
 	return
 }
 
+// Callable calls a function with this and args. A script exception is
+// returned as an *Exception, which carries the stack of the throw.
+type Callable func(this Value, args ...Value) (Value, error)
+
+// AssertFunction returns a Callable for value if it is a function, like a
+// closure, a method read from an object or a native function.
+func (self *VM) AssertFunction(value Value) (Callable, bool) {
+	if value == nil || !isCallable(value) {
+		return nil, false
+	}
+	return func(this Value, args ...Value) (Value, error) {
+		return self.Call(value, this, args...)
+	}, true
+}
+
+// Call calls function with this and args and returns its result. It may be
+// used once a script has run or from a native function during a run, a nil
+// this or argument is null. Use ExportTo to convert the result to a Go type.
+func (self *VM) Call(function Value, this Value, args ...Value) (Value, error) {
+	if function == nil || !isCallable(function) {
+		return nil, fmt.Errorf("vm: %s is not a function", typeName(self.runtime.toValue(function)))
+	}
+	if this == nil {
+		this = Const_Null_Value
+	}
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = self.runtime.toValue(arg)
+	}
+	return self.interruptible(func() (Value, error) {
+		result, ex := self.call(function, this, values...)
+		if ex != nil {
+			return nil, ex
+		}
+		return result, nil
+	})
+}
+
+// call invokes callee with this and args from Go code and runs the VM until
+// the callee returns, leaving the current context untouched.
+func (self *VM) call(callee Value, this Value, args ...Value) (Value, *Exception) {
+	self.pushCtx()
+	defer self.popCtx()
+	self.pc = -2
+	self.pushTryFrame(-2, -1)
+	defer self.popTryFrame()
+
+	self.push(this)
+	self.push(callee)
+	for _, arg := range args {
+		self.push(arg)
+	}
+	ex := self.try(func() {
+		Call(len(args)).exec(self)
+	})
+	for ex == nil && !self.halted() {
+		ex = self.runTryInner()
+	}
+	if ex != nil {
+		return nil, ex
+	}
+	return self.pop(), nil
+}
+
 func (self *VM) runTry() *Exception {
 	self.pushTryFrame(-2, -1)
 	defer self.popTryFrame()
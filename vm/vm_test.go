@@ -1,9 +1,31 @@
 package vm
 
 import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 )
 
+// runScriptTests runs each script in a new VM and compares the string of its
+// result.
+func runScriptTests(t *testing.T, tests map[string]string) {
+	t.Helper()
+	for script, expected := range tests {
+		result, err := CreateVM().RunScript(script)
+		if err != nil {
+			t.Errorf("%q: %v", script, err)
+			continue
+		}
+		if result.toString() != expected {
+			t.Errorf("%q: got %q, want %q", script, result.toString(), expected)
+		}
+	}
+}
+
 func TestOperator(t *testing.T) {
 	vm := &VM{
 		pc: 0,
@@ -91,3 +113,663 @@ func TestLogicalOperator(t *testing.T) {
 	vm.pc = 0
 	vm.clearStack()
 }
+
+func TestExceptionStackTrace(t *testing.T) {
+	program, err := Compile("script.dl", `fun getFebNum(n) {
+    if n == 1 {
+        throw {message: "boom"}
+    }
+    return getFebNum(n - 1)
+}
+getFebNum(2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CreateVM().RunProgram(program)
+	ex, ok := err.(*Exception)
+	if !ok {
+		t.Fatalf("expected *Exception, got %v", err)
+	}
+	expected := "\tat getFebNum (script.dl:3:9)\n\tat getFebNum (script.dl:5:12)\n\tat script.dl:7:1"
+	if ex.StackTrace() != expected {
+		t.Errorf("unexpected stack trace:\n%s", ex.StackTrace())
+	}
+}
+
+func TestRuntimeErrorCatchable(t *testing.T) {
+	tests := map[string]string{
+		"var rec = fun() { return rec() }\nrec()": "StackOverflowError",
+		"var a = 1\na()":               "TypeError",
+		"undefinedFun()":               "ReferenceError",
+		"var o = null\no.name":         "TypeError",
+		"throw RangeError(\"range\")":  "RangeError",
+		"1 % 0":                        "RangeError",
+		"var z = 0\nvar a = 5\na %= z": "RangeError",
+		"5n % 0n":                      "RangeError",
+		"[1].get(\"a\")":               "TypeError",
+		"[1].remove(9)":                "RangeError",
+		"[1].remove(-1)":               "RangeError",
+		"var g = [1].get\ng(0)":        "TypeError",
+	}
+	for script, name := range tests {
+		result, err := CreateVM().RunScript("var r\ntry {\n" + script + "\n} catch (e) {\nr = e.name\n}\nr")
+		if err != nil {
+			t.Fatalf("%q: %v", script, err)
+		}
+		if result.toString() != name {
+			t.Errorf("%q: caught %s, want %s", script, result.toString(), name)
+		}
+	}
+}
+
+func TestArrayMethods(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var a = [1, 2, 3]\na.get(1) + \"\" + a.get(5)":                                         "2null",
+		"var a = [1, 2, 3]\na.remove(0)\nvar s = \"\"\nfor var x of a { s += x }\ns + a.size()": "232",
+	})
+}
+
+func TestConditionalExpression(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var a = 5\na > 3 ? \"big\" : \"small\"":     "big",
+		"var a = 1\na > 3 ? \"big\" : \"small\"":     "small",
+		"var a = 4\na < 3 ? 1 : a < 5 ? 2 : 3":       "2",
+		"false ? 1 : true ? 2 : 3":                   "2",
+		"var a = 0\ntrue ? a = 1 : a = 2\na":         "1",
+		"var o = {k: 2 > 1 ? \"yes\" : \"no\"}\no.k": "yes",
+	})
+}
+
+func TestBitwiseOperator(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"1 | 2 ^ 3 & 1":                  "3",
+		"~5":                             "-6",
+		"1 << 4 + 1":                     "32",
+		"-16 >> 2":                       "-4",
+		"-16 >>> 60":                     "15",
+		"var f = 5\nf |= 8\nf ^= 1\nf":   "12",
+		"var f = 3\nf <<= 4\nf >>= 1\nf": "24",
+		"var f = -1\nf >>>= 63\nf":       "1",
+		"var hdr = {flags: 1}\nhdr.flags |= 6\nhdr.flags ^= 2\nhdr.flags <<= 4\nhdr.flags":      "80",
+		"var buf = [1, -64]\nvar i = 0\nbuf[i] <<= 8\nbuf[i + 1] >>= 2\n\"\" + buf[0] + buf[1]": "256-16",
+		"var buf = [-1]\nbuf[0] >>>= 60\nbuf[0] &= 7\nbuf[0]":                                   "7",
+	})
+}
+
+func TestExponentiationOperator(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"2 ** 10":                             "1024",
+		"2 ** 3 ** 2":                         "512",
+		"2 * 3 ** 2":                          "18",
+		"2 ** -1":                             "0.5",
+		"4 ** 0.5":                            "2",
+		"var a = 3\na **= 2\na":               "9",
+		"var a = 2\na **= a ** 2\na":          "16",
+		"-2 ** 2":                             "-4",
+		"(-2) ** 3":                           "-8",
+		"2 ** 63 == 2.0 ** 63":                "true",
+		"10 ** 30 > 10 ** 29":                 "true",
+		"var o = {x: 3}\no.x **= 2\no.x":      "9",
+		"var a = [2, 3]\na[1] **= a[0]\na[1]": "9",
+	})
+}
+
+func TestWhileStatement(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var i = 0\nvar s = 0\nwhile i < 10 {\ni++\nif i == 3 { continue }\nif i == 8 { break }\ns += i\n}\ns": "25",
+		"var i = 0\ndo {\ni++\n} while i < 0\ni":                                                          "1",
+		"var i = 0\nvar s = \"\"\ndo {\nvar x = i\ni++\nif x == 1 { continue }\ns += x\n} while i < 5\ns": "0234",
+		"fun f() {\nvar n = 0\nfor var i = 0; i < 3; i++ {\nvar j = 0\nwhile true {\nvar k = j\nj++\nif k == 2 { break }\nif k == 0 { continue }\nn += 10\n}\n}\nreturn n\n}\nf()": "30",
+		"fun f() {\nvar a = 1\nvar b = a++\nvar c = ++a\nvar d = 0\nd = a += 2\nreturn \"\" + a + b + c + d\n}\nf()":                                                               "5135",
+		"var n = 0\nfor var i = 0; i < 3; i++ {\nvar f = fun() {\nwhile true { break }\nreturn i\n}\nn += f()\n}\nn":                                                               "3",
+	})
+}
+
+func TestAssignTargets(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var o = {x: 1}\no.x += 2\no.x++\nvar a = o.x--\nvar b = ++o.x\n\"\" + a + b + o.x":                                                   "444",
+		"var a = [1, 2]\nvar i = 0\na[i++] += 10\na[i] *= 3\na[2] = 7\nvar b = a[0]++\n\"\" + a[0] + a[1] + a[2] + b":                         "126711",
+		"var calls = 0\nfun key() {\ncalls++\nreturn \"k\"\n}\nvar o = {k: 1}\no[key()] += 1\no[key()]++\n\"\" + o.k + calls":                 "32",
+		"class C {\npublic n = 1\npublic C() {}\npublic add(k) {\nthis.n += k\nreturn this.n++\n}\n}\nvar c = new C()\n\"\" + c.add(2) + c.n": "34",
+		"var o = {}\nvar r = o.x = 5\nr": "5",
+	})
+}
+
+func TestIterationStatement(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var s = \"\"\nfor var x of [1, 2, 3] {\ns += x\n}\ns":                                                                                                            "123",
+		"var s = \"\"\nfor var k in {b: 2, a: 1, c: 3} {\ns += k\n}\nfor var k, v in {b: 2, a: 1} {\ns += k + v\n}\ns":                                                    "abca1b2",
+		"var s = \"\"\nfor var i, x in [\"a\", \"b\"] {\ns += i + x\n}\ns":                                                                                                "0a1b",
+		"var fs = {f1: 0, f2: 0}\nfor var x of [1, 2] {\nif x == 1 { fs.f1 = fun() { return x } }\nif x == 2 { fs.f2 = fun() { return x } }\n}\n\"\" + fs.f1() + fs.f2()": "12",
+		"fun f() {\nvar s = \"\"\nfor var k, v in {a: 1, b: 2, c: 3, d: 4} {\nif k == \"b\" { continue }\nif k == \"d\" { break }\ns += k + v\n}\nreturn s\n}\nf()":       "a1c3",
+		"class Range {\nprivate n\npublic Range(n) {\nthis.n = n\n}\npublic iterator() {\nvar self = this\nvar i = 0\nreturn {hasNext: fun() { return i < self.n }, next: fun() { i++\nreturn i }}\n}\n}\nvar s = \"\"\nfor var x of new Range(3) {\ns += x + \",\"\n}\ns": "1,2,3,",
+		"var o = {a: 1, iterator: fun() { return {hasNext: fun() { return true }, next: fun() { return 0 }} }}\nvar s = \"\"\nfor var k in o {\ns += k + \",\"\n}\ns":                                                                                                      "a,iterator,",
+		"var r\ntry {\nfor var x of 5 {\n}\n} catch (e) {\nr = e.name\n}\nr": "TypeError",
+	})
+}
+
+func TestLabelledStatement(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var found = \"\"\nouter: for var i = 0; i < 5; i++ {\nfor var j = 0; j < 5; j++ {\nif j > i { continue outer }\nif i * j == 6 {\nfound = \"\" + i + j\nbreak outer\n}\n}\n}\nfound":          "32",
+		"fun f() {\nvar s = \"\"\nrows: for var r of [[1, 2], [3, 4], [5, 6]] {\nfor var c of r {\nif c == 4 { continue rows }\nif c == 6 { break rows }\ns += c\n}\ns += \"|\"\n}\nreturn s\n}\nf()": "12|35",
+		"var n = 0\nblock: {\nvar k = 1\nn += k\nif n > 0 { break block }\nn = 100\n}\nn":                                                                                                             "1",
+		"var w = 0\nloop: while true {\ndo {\nw++\nif w > 3 { break loop }\ncontinue loop\n} while true\n}\nw":                                                                                        "4",
+	})
+	for _, script := range []string{
+		"a: for var i = 0; i < 1; i++ { break b }",
+		"a: { for var i = 0; i < 1; i++ { continue a } }",
+		"a: a: while true { break a }",
+	} {
+		if _, err := Compile("script.dl", script); err == nil {
+			t.Errorf("%q: expected a syntax error", script)
+		}
+	}
+}
+
+func TestBranchThroughTry(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"while true { try { break } catch (e) {} }\nvar r\ntry { throw 1 } catch (e) { r = e }\nr":                                                                                                            "1",
+		"var s = 0\nwhile true { try { break } finally { s = 1 } }\ns":                                                                                                                                        "1",
+		"var s = \"\"\nwhile true {\ntry { throw 1 } catch (e) {\ns += e\nbreak\n} finally { s += \"f\" }\n}\ntry { throw 2 } catch (e) { s += e }\ns":                                                        "1f2",
+		"var s = \"\"\nfor var i = 0; i < 3; i++ {\ntry {\nif i == 1 { continue }\ns += i\n} catch (e) {}\n}\ntry { throw \"c\" } catch (e) { s += e }\ns":                                                    "02c",
+		"var s = \"\"\nfor var i = 0; i < 3; i++ {\ntry {\nif i == 1 { continue }\ns += i\n} finally { s += \"f\" }\n}\ns":                                                                                    "0ff2f",
+		"var s = \"\"\nouter: for var i = 0; i < 3; i++ {\nfor var j = 0; j < 3; j++ {\ntry {\nif j == 1 { continue outer }\nif i == 2 { break outer }\ns += i + \"\" + j\n} finally { s += \"f\" }\n}\n}\ns": "00ff10fff",
+		"var s = \"\"\nloop: for var x of [1, 2] {\ntry {\ntry { break loop } finally { s += \"a\" }\n} catch (e) {} finally { s += \"b\" }\n}\ns":                                                            "ab",
+		"var s = \"\"\nb: {\ntry { break b } finally { s += \"f\" }\ns += \"x\"\n}\ns":                                                                                                                        "f",
+	})
+	_, err := CreateVM().RunScript("while true { try { break } catch (e) {} }\nthrow 1")
+	if _, ok := err.(*Exception); !ok {
+		t.Errorf("expected an uncaught exception, got %v", err)
+	}
+}
+
+func TestLexicalDeclaration(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"let a\nconst b = 2\na = 3\na + b":                                                   "5",
+		"fun f() {\nlet x = 10\nconst y = 20\n{\nlet x = 1\nx += y\n}\nreturn x + y\n}\nf()": "30",
+		"fun f() {\nlet fs = {f0: 0, f1: 0, f2: 0}\nfor let i = 0; i < 3; i++ {\nif i == 0 { fs.f0 = fun() { return i } }\nif i == 1 { fs.f1 = fun() { return i } }\nif i == 2 { fs.f2 = fun() { return i } }\n}\nreturn \"\" + fs.f0() + fs.f1() + fs.f2()\n}\nf()": "012",
+		"fun f() {\nvar s = \"\"\nfor const x of [1, 2, 3] {\ns += x\n}\nreturn s\n}\nf()":          "123",
+		"fun f() {\nvar r\ntry {\nr = z\n} catch (e) {\nr = e.name\n}\nlet z = 1\nreturn r\n}\nf()": "ReferenceError",
+	})
+	for _, script := range []string{
+		"const a = 1\na = 2",
+		"fun f() {\nconst a = 1\nreturn fun() { a++ }\n}",
+		"const a",
+		"let a = 1\nlet a = 2",
+	} {
+		if _, err := Compile("script.dl", script); err == nil {
+			t.Errorf("%q: expected a syntax error", script)
+		}
+	}
+	vm := CreateVM()
+	if _, err := vm.RunScript("const limit = 3"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := vm.RunScript("var r\ntry {\nlimit = 4\n} catch (e) {\nr = e.name\n}\nr + limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.toString() != "TypeError3" {
+		t.Errorf("got %s, want TypeError3", result.toString())
+	}
+	// A later script may neither declare a global let or const again nor
+	// read one before its declaration has run.
+	for _, script := range []string{"var limit = 4", "const limit = 4", "fun limit() {}"} {
+		if _, err := vm.RunScript(script); err == nil || !strings.Contains(err.Error(), "SyntaxError: Identifier 'limit' has already been declared") {
+			t.Errorf("%q: got %v, want a SyntaxError", script, err)
+		}
+	}
+	if result, err := vm.RunScript("limit"); err != nil || result.toString() != "3" {
+		t.Errorf("limit: got %v %v, want 3", result, err)
+	}
+	if _, err := CreateVM().RunScript("var r = x\nlet x = 1"); err == nil || !strings.Contains(err.Error(), "ReferenceError: Cannot access 'x' before initialization") {
+		t.Errorf("got %v, want a ReferenceError for x", err)
+	}
+	if _, err := CreateVM().RunScript("x = 2\nlet x = 1"); err == nil || !strings.Contains(err.Error(), "ReferenceError: Cannot access 'x' before initialization") {
+		t.Errorf("got %v, want a ReferenceError for x", err)
+	}
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"var n = 2\n`${n} + ${n} = ${n + n}`":           "2 + 2 = 4",
+		"`line 1\nline 2`":                              "line 1\nline 2",
+		"var o = {a: 1}\n`x${ {a: o.a}.a }y`":           "x1y",
+		"`a${`b${1}`}c`":                                "ab1c",
+		"`cost: $5 \\`q\\` \\u{41}`":                    "cost: $5 `q` A",
+		"``":                                            "",
+		"fun f(x) { return `<${x}>` }\nf(1) + f(\"b\")": "<1><b>",
+		"fun tag(s, a, b) {\nreturn s.get(0) + \"[\" + a + \"]\" + s.get(1) + \"[\" + b + \"]\" + s.get(2) + \"|\" + s.raw.get(0) + s.size()\n}\ntag`x\\n${1}y${2}z`": "x\n[1]y[2]z|x\\n3",
+	})
+	for _, script := range []string{"`abc", "`${1 2}`", "`${1`"} {
+		if _, err := Compile("script.dl", script); err == nil {
+			t.Errorf("%q: expected a syntax error", script)
+		}
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	runScriptTests(t, map[string]string{
+		"9223372036854775807n + 1n":                                               "9223372036854775808",
+		"var a = 9223372036854775807n\na * a":                                     "85070591730234615847396907784232501249",
+		"2n ** 100n":                                                              "1267650600228229401496703205376",
+		"7n / 2n + \",\" + -7n % 2n":                                              "3,-1",
+		"var x = 0xFFn\nx + 1":                                                    "256",
+		"1n + 0.5":                                                                "1.5",
+		"BigInt(\"123456789012345678901234567890\") + 0n":                         "123456789012345678901234567890",
+		"\"\" + (10n > 9) + (2n == 2) + (1n < 1.5)":                               "truetruetrue",
+		"var r\ntry {\n1n / 0n\n} catch (e) {\nr = e.name\n}\nr":                  "RangeError",
+		"var r\ntry {\nBigInt(1.5)\n} catch (e) {\nr = e.name\n}\nr":              "RangeError",
+		"var i = 1n\ni++\nvar j = -i\nj << 3n":                                    "-16",
+		"\"\" + (5n >> 100000000000000000000n) + (-5n >> 100000000000000000000n)": "0-1",
+		"\"\" + (0n << 100000000000000000000n) + (1n ** 100000000000000000000n)":  "01",
+		"var r\ntry {\n1n << 100000000000n\n} catch (e) {\nr = e.name\n}\nr":      "RangeError",
+		"var r\ntry {\n2n ** 10000000000n\n} catch (e) {\nr = e.name\n}\nr":       "RangeError",
+		"var r\ntry {\n1n >> -100000000000n\n} catch (e) {\nr = e.name\n}\nr":     "RangeError",
+	})
+	if literal := ToBigIntValue(big.NewInt(5)).toLiteral(); literal != "5n" {
+		t.Errorf("got literal %s, want 5n", literal)
+	}
+
+	script := "var max = 9223372036854775807\nmax + 1"
+	result, err := CreateVM().RunScript(script)
+	if err != nil || result.toInt() != math.MinInt64 {
+		t.Errorf("unchecked: got %v, %v", result, err)
+	}
+	vm := CreateVM()
+	vm.SetCheckedArithmetic(true)
+	for _, script := range []string{script, "9223372036854775807 + 1", "-9223372036854775807 - 2", "4611686018427387904 * 2", "3 ** 40"} {
+		if _, err := vm.RunScript(script); err == nil || !strings.Contains(err.Error(), "RangeError") {
+			t.Errorf("%q: expected a RangeError, got %v", script, err)
+		}
+	}
+}
+
+func TestValueConversion(t *testing.T) {
+	vm := CreateVM()
+	result, err := vm.RunScript("var o = {name: \"a\", count: 2, ratio: 0.5, tags: [\"x\", \"y\"], big: 2n ** 70n, none: null}\no")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := result.Export().(map[string]any)
+	if exported["name"] != "a" || exported["count"] != int64(2) || exported["ratio"] != 0.5 || exported["none"] != nil ||
+		exported["big"].(*big.Int).String() != "1180591620717411303424" || len(exported["tags"].([]any)) != 2 {
+		t.Errorf("exported %v", exported)
+	}
+
+	var target struct {
+		Name  string
+		Count int8
+		Tags  []string
+		Ratio *float64
+	}
+	if err := vm.ExportTo(result, &target); err != nil || target.Name != "a" || target.Count != 2 ||
+		strings.Join(target.Tags, ",") != "x,y" || *target.Ratio != 0.5 {
+		t.Errorf("struct %+v, %v", target, err)
+	}
+	var counts map[string]int
+	if err := vm.ExportTo(result, &counts); err == nil {
+		t.Errorf("exported a string property to int: %v", counts)
+	}
+	var small uint8
+	if err := vm.ExportTo(ToIntValue(300), &small); err == nil {
+		t.Error("exported 300 to uint8")
+	}
+
+	object, _ := AssertObject(result)
+	object.Set("count", vm.ToValue(uint64(math.MaxUint64)))
+	if object.Get("count").toString() != "18446744073709551615" || strings.Join(object.Keys(), ",") != "big,count,name,none,ratio,tags" {
+		t.Errorf("object %s, keys %v", object.toLiteral(), object.Keys())
+	}
+	tags, _ := AssertObject(object.Get("tags"))
+	tags.SetIndex(3, ToStringValue("z"))
+	if tags.Length() != 4 || tags.GetIndex(2) != Const_Null_Value || tags.GetIndex(3).toString() != "z" || tags.GetIndex(4) != nil {
+		t.Errorf("array %s", tags.toLiteral())
+	}
+	tags.Set("own", ToIntValue(1))
+	if vm.NewArray().Get("own") != nil {
+		t.Error("array properties are shared")
+	}
+
+	type point struct {
+		X, Y int
+	}
+	vm.runtime.globalObject.self.setProperty("config", vm.ToValue(map[string]any{
+		"points": []point{{1, 2}, {3, 4}},
+		"scale":  func(p point, factor float64) (float64, error) { return float64(p.X+p.Y) * factor, nil },
+		"fail":   func() error { return errors.New("failed") },
+		"join":   func(separator string, parts ...string) string { return strings.Join(parts, separator) },
+	}))
+	result, err = vm.RunScript("var p = config.points.get(1)\nvar r\ntry { config.fail() } catch (e) { r = e.message }\n" +
+		"r + \",\" + config.scale(p, 0.5) + \",\" + config.join(\"-\", \"a\", \"b\", \"c\") + \",\" + p.X")
+	if err != nil || result.toString() != "failed,3.5,a-b-c,3" {
+		t.Errorf("calling Go functions: %v, %v", result, err)
+	}
+
+	result, err = vm.RunScript("var divide = fun(a, b) {\nif b == 0 { throw Error(\"zero\") }\nreturn a / b\n}\ndivide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var divide func(int, int) (float64, error)
+	if err := vm.ExportTo(result, &divide); err != nil {
+		t.Fatal(err)
+	}
+	if quotient, err := divide(7, 2); quotient != 3.5 || err != nil {
+		t.Errorf("divide(7, 2) = %v, %v", quotient, err)
+	}
+	if _, err := divide(1, 0); err == nil || !strings.Contains(err.Error(), "zero") {
+		t.Errorf("divide(1, 0) error %v", err)
+	}
+
+	// Maps and slices holding themselves keep doing so, types without a
+	// Value form throw a TypeError scripts can catch.
+	cyclic := map[string]any{"name": "root"}
+	cyclic["self"] = cyclic
+	list := []any{1, nil}
+	list[1] = list
+	loop := new(any)
+	*loop = loop
+	vm.Set("cyclic", cyclic)
+	vm.Set("list", list)
+	vm.Set("pointer", loop)
+	vm.Set("channel", func() chan int { return make(chan int) })
+	result, err = vm.RunScript("var r\ntry { channel() } catch (e) { r = e.name }\n" +
+		"cyclic.self.self.name + list.get(1).get(1).get(0) + pointer + r")
+	if err != nil || result.toString() != "root1nullTypeError" {
+		t.Errorf("converting cyclic values: %v, %v", result, err)
+	}
+}
+
+func TestHostFunctions(t *testing.T) {
+	vm := CreateVM()
+	users := map[int64]string{1: "ann", 2: "bob"}
+	vm.RegisterFunc("lookup", func(call FunctionCall) Value {
+		id := call.Argument(0)
+		if !id.isInt() {
+			call.ThrowTypeError("id must be an int, got %s", id.toLiteral())
+		}
+		name, ok := users[id.toInt()]
+		if !ok {
+			call.ThrowError("no user %d", id.toInt())
+		}
+		return call.VM().ToValue(name)
+	})
+	vm.RegisterModule("strings", map[string]any{
+		"upper":     strings.ToUpper,
+		"separator": ",",
+		"join": func(call FunctionCall) Value {
+			separator := call.This.toObject().self.getProperty("separator").toString()
+			var parts []string
+			for _, argument := range call.Arguments {
+				parts = append(parts, argument.toString())
+			}
+			return ToStringValue(strings.Join(parts, separator))
+		},
+	})
+	vm.Set("limit", 3)
+	tests := map[string]string{
+		"lookup(2)": "bob",
+		"strings.join(strings.upper(lookup(1)), limit, lookup.name)":                "ANN,3,lookup",
+		"var r\ntry { lookup(3) } catch (e) { r = e.name + \": \" + e.message }\nr": "Error: no user 3",
+		"var r\ntry { lookup(\"a\") } catch (e) { r = e.name }\nr":                  "TypeError",
+		"var r\ntry { strings.upper(1) } catch (e) { r = e.name }\nr":               "TypeError",
+		"new lookup(1)": "ann",
+	}
+	for script, expected := range tests {
+		result, err := vm.RunScript(script)
+		if err != nil {
+			t.Fatalf("%q: %v", script, err)
+		}
+		if result.toString() != expected {
+			t.Errorf("%q: got %s, want %s", script, result.toString(), expected)
+		}
+	}
+	// new used to leave the receiver slot on the stack, breaking the next run.
+	vm.RunScript("new lookup(1)")
+	if result, err := vm.RunScript("var r\ntry { lookup(3) } catch (e) { r = e.name }\nr"); err != nil || result.toString() != "Error" {
+		t.Errorf("after new: %v, %v", result, err)
+	}
+	if vm.Get("limit").toInt() != 3 || vm.Get("missing") != nil {
+		t.Errorf("globals limit %v, missing %v", vm.Get("limit"), vm.Get("missing"))
+	}
+}
+
+type testAddress struct {
+	City string
+}
+
+type testUser struct {
+	ID       int
+	FullName string `script:"name"`
+	Secret   string `script:"-"`
+	Address  testAddress
+	Tags     []string
+}
+
+func (self *testUser) Greet(greeting string) string {
+	return greeting + ", " + self.FullName
+}
+
+func (self *testUser) Rename(name string) error {
+	if name == "" {
+		return errors.New("empty name")
+	}
+	self.FullName = name
+	return nil
+}
+
+func TestReflectBinding(t *testing.T) {
+	tests := []struct {
+		mapper   FieldNameMapper
+		script   string
+		expected string
+	}{
+		{nil, "user.FullName + user.ID + user.Greet(\"hi\")", "ann1hi, ann"},
+		{UncapFieldNameMapper(), "user.id = 7\nuser.address.city = \"Oslo\"\nuser.fullName + user.id + user.address.city", "ann7Oslo"},
+		{TagFieldNameMapper("script", true), "user.rename(\"bob\")\nuser.name + user.greet(\"hey\") + user.Secret", "bobhey, bobnull"},
+		{TagFieldNameMapper("script", true), "var r\ntry { user.rename(\"\") } catch (e) { r = e.message }\nr + user.name", "empty nameann"},
+		{TagFieldNameMapper("script", false), "var r\ntry { user.ID = \"x\" } catch (e) { r = e.name }\nr", "TypeError"},
+		{UncapFieldNameMapper(), "var s = \"\"\nfor var k, v in user { s += k + \",\" }\ns", "id,fullName,secret,address,tags,"},
+		{UncapFieldNameMapper(), "user.greet == user.greet", "true"},
+	}
+	for _, test := range tests {
+		vm := CreateVM()
+		vm.SetFieldNameMapper(test.mapper)
+		user := &testUser{ID: 1, FullName: "ann", Secret: "s"}
+		vm.Set("user", user)
+		result, err := vm.RunScript(test.script)
+		if err != nil {
+			t.Fatalf("%q: %v", test.script, err)
+		}
+		if result.toString() != test.expected {
+			t.Errorf("%q: got %s, want %s", test.script, result.toString(), test.expected)
+		}
+		if exported := vm.Get("user").Export(); exported != user {
+			t.Errorf("%q: exported %v", test.script, exported)
+		}
+	}
+
+	vm := CreateVM()
+	vm.SetFieldNameMapper(UncapFieldNameMapper())
+	user := &testUser{FullName: "ann", Tags: []string{"a"}}
+	vm.Set("user", user)
+	if _, err := vm.RunScript("user.id = 3\nuser.address.city = \"Rome\""); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 3 || user.Address.City != "Rome" {
+		t.Errorf("user %+v", user)
+	}
+	// Slice fields are copies, only assigning the field changes the struct.
+	if _, err := vm.RunScript("user.tags.add(\"b\")"); err != nil || len(user.Tags) != 1 {
+		t.Errorf("tags after add %v, %v", user.Tags, err)
+	}
+	if _, err := vm.RunScript("var tags = user.tags\ntags.add(\"b\")\nuser.tags = tags"); err != nil || len(user.Tags) != 2 || user.Tags[1] != "b" {
+		t.Errorf("tags after assign %v, %v", user.Tags, err)
+	}
+	var copied testUser
+	if err := vm.ExportTo(vm.Get("user"), &copied); err != nil || copied.ID != 3 {
+		t.Errorf("copied %+v, %v", copied, err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	vm := CreateVM()
+	if _, err := vm.RunScript(`class Counter {
+public count = 0
+public Counter(start) {
+this.count = start
+}
+public add(n) {
+this.count = this.count + n
+return this.count
+}
+}
+fun makeFilter(limit) {
+return (x) -> x < limit
+}
+var counter = new Counter(10)
+var small = makeFilter(3)
+fun fail(message) {
+throw Error(message)
+}`); err != nil {
+		t.Fatal(err)
+	}
+
+	small, ok := vm.AssertFunction(vm.Get("small"))
+	if !ok {
+		t.Fatal("small is not a function")
+	}
+	var kept []int64
+	for i := int64(0); i < 5; i++ {
+		result, err := small(nil, ToIntValue(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.toBool() {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) != 3 {
+		t.Errorf("kept %v", kept)
+	}
+
+	counter, _ := AssertObject(vm.Get("counter"))
+	if result, err := vm.Call(counter.Get("add"), counter, ToIntValue(5)); err != nil || result.toInt() != 15 {
+		t.Errorf("counter.add(5) = %v, %v", result, err)
+	}
+	if result, err := vm.Call(vm.Get("BigInt"), nil, ToStringValue("12")); err != nil || result.toLiteral() != "12n" {
+		t.Errorf("BigInt(\"12\") = %v, %v", result, err)
+	}
+
+	_, err := vm.Call(vm.Get("fail"), nil, ToStringValue("boom"))
+	var exception *Exception
+	if !errors.As(err, &exception) || exception.Value().toObject().Get("message").toString() != "boom" ||
+		len(exception.Stack()) == 0 || exception.Stack()[0].FunctionName() != "fail" {
+		t.Errorf("fail error %v", err)
+	}
+	if _, err := vm.Call(ToIntValue(1), nil); err == nil {
+		t.Error("called an int")
+	}
+	if _, ok := vm.AssertFunction(counter); ok {
+		t.Error("an object is not a function")
+	}
+
+	var transform Callable
+	if err := vm.ExportTo(vm.Get("small"), &transform); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := transform(nil, ToIntValue(1)); err != nil || !result.toBool() {
+		t.Errorf("transform(1) = %v, %v", result, err)
+	}
+	if result, err := vm.RunScript("counter.count"); err != nil || result.toInt() != 15 {
+		t.Errorf("script after calls %v, %v", result, err)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	vm := CreateVM()
+	var caught int64
+	vm.RegisterFunc("caught", func(call FunctionCall) Value {
+		caught++
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := vm.RunScriptContext(ctx, `fun spin() {
+for ;; {}
+}
+try {
+spin()
+} catch (e) {
+caught()
+} finally {
+caught()
+}`)
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v", err)
+	}
+	if len(interrupted.Stack()) == 0 || interrupted.Stack()[0].FunctionName() != "spin" {
+		t.Errorf("stack %v", interrupted.Stack())
+	}
+	if caught != 0 {
+		t.Errorf("the interrupt was caught %d times", caught)
+	}
+
+	// The VM keeps working and a finished context does not stop later runs.
+	if result, err := vm.RunScript(`try { throw 1 } catch (e) { caught() }
+1 + 2`); err != nil || result.toInt() != 3 || caught != 1 {
+		t.Errorf("run after interrupt = %v, %v, caught %d", result, err, caught)
+	}
+	if _, err := vm.RunScriptContext(ctx, `1`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run with done context = %v", err)
+	}
+	if _, err := vm.RunScript(`1`); err != nil {
+		t.Errorf("run after done context = %v", err)
+	}
+
+	// Interrupts from another goroutine stop functions called from Go.
+	if _, err := vm.RunScript(`fun loop() { while true {} }`); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		vm.Interrupt("stop")
+	}()
+	if _, err := vm.Call(vm.Get("loop"), nil); !errors.As(err, &interrupted) || interrupted.Reason() != "stop" {
+		t.Errorf("loop error %v", err)
+	}
+
+	// Interrupts stop exported funcs, returned as their error, also when they
+	// are called from a native function during a run.
+	var loop func() error
+	if err := vm.ExportTo(vm.Get("loop"), &loop); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		vm.Interrupt("stop")
+	}()
+	if err := loop(); !errors.As(err, &interrupted) || interrupted.Reason() != "stop" {
+		t.Errorf("exported loop error %v", err)
+	}
+	vm.RegisterFunc("callLoop", func(call FunctionCall) Value {
+		if err := loop(); !errors.As(err, &interrupted) {
+			t.Errorf("exported loop in a run error %v", err)
+		}
+		return nil
+	})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		vm.Interrupt("stop")
+	}()
+	if _, err := vm.RunScript(`callLoop()`); !errors.As(err, &interrupted) {
+		t.Errorf("run calling the exported loop error %v", err)
+	}
+
+	vm.Interrupt("pending")
+	vm.ClearInterrupt()
+	if _, err := vm.RunScript(`1`); err != nil {
+		t.Errorf("run after ClearInterrupt = %v", err)
+	}
+}